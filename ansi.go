@@ -0,0 +1,22 @@
+package codex
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiSequence matches CSI sequences (colors, cursor movement), OSC sequences
+// (titles, hyperlinks), and other two-byte escape sequences.
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripANSI removes ANSI escape sequences and control characters other than
+// newline, carriage return, and tab from s.
+func stripANSI(s string) string {
+	s = ansiSequence.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\n' && r != '\r' && r != '\t') || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
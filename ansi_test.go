@@ -0,0 +1,64 @@
+package codex
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "hello\n\tworld", "hello\n\tworld"},
+		{"color", "\x1b[1;31merror\x1b[0m: done", "error: done"},
+		{"cursor", "a\x1b[2Kb\x1b[1A", "ab"},
+		{"osc_hyperlink", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x07", "link"},
+		{"control_chars", "bell\x07 back\x08space\r\n", "bell backspace\r\n"},
+		{"unicode", "café ✓", "café ✓"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripANSI(tt.in); got != tt.want {
+				t.Errorf("stripANSI(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStripANSI(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"\u001b[32mok\u001b[0m"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	turn, err := thread.Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "\x1b[32mok\x1b[0m"; turn.FinalResponse != want {
+		t.Errorf("expected escape codes preserved by default, got %q", turn.FinalResponse)
+	}
+
+	turn, err = thread.Run(testContext(t), Text("hello"), WithStripANSI())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if turn.FinalResponse != "ok" {
+		t.Errorf("expected escape codes stripped, got %q", turn.FinalResponse)
+	}
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"), WithStripANSI())
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	for range streamed.Events {
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if got := streamed.MessageText("1"); got != "ok" {
+		t.Errorf("expected escape codes stripped from MessageText, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package codex
+
+import "time"
+
+// Clock is the source of time for timeouts and event timestamps. The default
+// uses the time package; tests can supply a fake with WithClock to drive
+// timing behavior deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call created by Clock.AfterFunc. Its methods behave
+// like those of *time.Timer.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// clockOrDefault returns c, or the system clock when c is nil.
+func clockOrDefault(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
@@ -0,0 +1,123 @@
+package codex
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	when   time.Time
+	f      func()
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, when: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d and runs the timers that came due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	for _, timer := range c.timers {
+		if timer.active && !timer.when.After(c.now) {
+			timer.active = false
+			due = append(due, timer.f)
+		}
+	}
+	c.mu.Unlock()
+	for _, f := range due {
+		go f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.when = t.clock.now.Add(d)
+	t.active = true
+	return wasActive
+}
+
+func TestWithClockDrivesIdleTimeout(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+exec sleep 5
+`)
+	clock := newFakeClock()
+	client, err := New(WithCodexPath(script), WithClock(clock))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	streamed, err := client.StartThread().RunStreamed(testContext(t), Text("hello"), WithIdleTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+
+	first := <-streamed.Events
+	if first.Type != EventThreadStarted {
+		t.Fatalf("expected thread.started, got %s", first.Type)
+	}
+	if !first.ReceivedAt.Equal(clock.Now()) {
+		t.Errorf("expected ReceivedAt from the fake clock %s, got %s", clock.Now(), first.ReceivedAt)
+	}
+
+	// The hour-long timeout only elapses on the fake clock. Keep advancing
+	// until the turn ends, since the idle timer is re-armed asynchronously
+	// after the event is delivered.
+	done := make(chan error, 1)
+	go func() {
+		for range streamed.Events {
+		}
+		done <- streamed.Wait()
+	}()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrIdleTimeout) {
+				t.Fatalf("expected ErrIdleTimeout, got %v", err)
+			}
+			return
+		case <-deadline:
+			t.Fatal("idle timeout did not fire after advancing the fake clock")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(time.Hour)
+		}
+	}
+}
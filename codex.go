@@ -1,12 +1,24 @@
 package codex
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
 // Codex is the main entry point for interacting with the Codex agent.
 //
 // Use New() to create a client, then StartThread() to begin a new conversation
-// or ResumeThread() to continue an existing one.
+// or ResumeThread() to continue an existing one. A Codex client is safe for
+// concurrent use by multiple goroutines.
 type Codex struct {
-	exec    *Exec
-	options CodexOptions
+	exec     *Exec
+	options  CodexOptions
+	turns    *activeTurns
+	tempDirs *tempDirs
+	slots    semaphore
+	eventLog *eventLog
 }
 
 // New creates a new Codex client with the given options.
@@ -30,10 +42,44 @@ func New(opts ...Option) (*Codex, error) {
 	if err != nil {
 		return nil, err
 	}
+	if options.EnvFile != "" {
+		if exec.envFile, err = loadEnvFile(options.EnvFile); err != nil {
+			return nil, err
+		}
+	}
+	exec.homeDir = options.HomeDir
+	exec.originator = options.Originator
+	exec.telemetryDisabled = options.TelemetryDisabled
+	exec.requestIDKey = options.RequestIDKey
+	exec.stderrLimit = options.StderrLimit
+	exec.extraArgs = options.ExtraArgs
+	exec.processGroup = options.ProcessGroup
+
+	if options.BinaryChecksum != "" {
+		if err := exec.verifyChecksum(options.BinaryChecksum); err != nil {
+			return nil, err
+		}
+	}
+
+	var slots semaphore
+	if options.MaxConcurrency > 0 {
+		slots = make(semaphore, options.MaxConcurrency)
+	}
+
+	var log *eventLog
+	if options.CompressedEventLog != "" {
+		if log, err = openEventLog(options.CompressedEventLog); err != nil {
+			return nil, err
+		}
+	}
 
 	return &Codex{
-		exec:    exec,
-		options: options,
+		exec:     exec,
+		options:  options,
+		turns:    newActiveTurns(),
+		tempDirs: &tempDirs{},
+		slots:    slots,
+		eventLog: log,
 	}, nil
 }
 
@@ -55,12 +101,22 @@ func (c *Codex) StartThread(opts ...ThreadOption) *Thread {
 		exec:          c.exec,
 		codexOptions:  c.options,
 		threadOptions: threadOptions,
+		id:            threadOptions.SessionID,
+		turns:         c.turns,
+		tempDirs:      c.tempDirs,
+		slots:         c.slots,
+		eventLog:      c.eventLog,
 	}
 }
 
 // ResumeThread resumes a conversation based on the thread ID.
 // Threads are persisted in ~/.codex/sessions.
 //
+// The thread runs with opts alone; nothing is carried over from the options
+// the conversation was started with, so settings such as the sandbox mode or
+// model can change on resume. Every flag is passed to "codex exec" ahead of
+// the resume subcommand, where the CLI applies it to the resumed session.
+//
 // Example:
 //
 //	savedID := "thread_abc123"
@@ -73,5 +129,131 @@ func (c *Codex) ResumeThread(id string, opts ...ThreadOption) *Thread {
 		codexOptions:  c.options,
 		threadOptions: threadOptions,
 		id:            id,
+		turns:         c.turns,
+		tempDirs:      c.tempDirs,
+		slots:         c.slots,
+		eventLog:      c.eventLog,
+	}
+}
+
+// Close removes temporary files created for the client's threads, such as
+// instruction files written for WithInstructions and worktrees created for
+// WithGitWorktree, and closes the WithCompressedEventLog file. Threads created
+// by the client should not be run after Close.
+func (c *Codex) Close() error {
+	return errors.Join(c.tempDirs.removeAll(), c.eventLog.close())
+}
+
+// Shutdown cancels every turn running on threads created by this client and
+// waits for their codex processes to exit, bounded by ctx. Each process is
+// sent SIGTERM (an interrupt on Windows) so that it can clean up, and is
+// killed if it has not exited a second later. Turns cancelled by Shutdown,
+// and turns started after it, fail with ErrShutdown.
+func (c *Codex) Shutdown(ctx context.Context) error {
+	return c.turns.shutdown(ctx)
+}
+
+// Ping checks that the codex binary can be run by invoking "codex --version".
+// It reports a missing or non-executable binary, or one that exits with an
+// error, before any real work is started. A non-zero exit is reported as an
+// error wrapping *ErrExecFailed.
+func (c *Codex) Ping(ctx context.Context) error {
+	if _, err := c.exec.Output(ctx, "--version"); err != nil {
+		return fmt.Errorf("ping codex: %w", err)
+	}
+	return nil
+}
+
+// activeTurns tracks running turns so they can be cancelled together.
+type activeTurns struct {
+	mu       sync.Mutex
+	turns    map[*activeTurn]struct{}
+	shutDown bool
+}
+
+// activeTurn is a running turn registered with activeTurns.
+type activeTurn struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+func newActiveTurns() *activeTurns {
+	return &activeTurns{turns: make(map[*activeTurn]struct{})}
+}
+
+// add registers a running turn. It fails with ErrShutdown once shutdown has
+// begun. A nil registry accepts every turn without tracking it.
+func (a *activeTurns) add(cancel context.CancelCauseFunc) (*activeTurn, error) {
+	turn := &activeTurn{cancel: cancel, done: make(chan struct{})}
+	if a == nil {
+		return turn, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.shutDown {
+		return nil, ErrShutdown
+	}
+	a.turns[turn] = struct{}{}
+	return turn, nil
+}
+
+// remove marks turn as finished.
+func (a *activeTurns) remove(turn *activeTurn) {
+	if a != nil {
+		a.mu.Lock()
+		delete(a.turns, turn)
+		a.mu.Unlock()
+	}
+	close(turn.done)
+}
+
+func (a *activeTurns) shutdown(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	a.shutDown = true
+	pending := make([]*activeTurn, 0, len(a.turns))
+	for turn := range a.turns {
+		pending = append(pending, turn)
+	}
+	a.mu.Unlock()
+
+	for _, turn := range pending {
+		turn.cancel(ErrShutdown)
+	}
+	for _, turn := range pending {
+		select {
+		case <-turn.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// semaphore limits the number of concurrently running codex processes. A nil
+// semaphore imposes no limit.
+type semaphore chan struct{}
+
+// acquire blocks until a slot is free or ctx is done.
+func (s semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// release frees a slot taken by acquire.
+func (s semaphore) release() {
+	if s != nil {
+		<-s
 	}
 }
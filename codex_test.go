@@ -1,8 +1,19 @@
 package codex
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNormalizeInput_TextOnly(t *testing.T) {
@@ -20,10 +31,14 @@ func TestNormalizeInput_TextOnly(t *testing.T) {
 }
 
 func TestNormalizeInput_Compose(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(imagePath, []byte("png"), 0o644); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
 	input := Compose(
 		TextPart("First part"),
 		TextPart("Second part"),
-		ImagePart("/path/to/image.png"),
+		ImagePart(imagePath),
 	)
 	prompt, images, err := normalizeInput(input)
 	if err != nil {
@@ -36,8 +51,8 @@ func TestNormalizeInput_Compose(t *testing.T) {
 	if len(images) != 1 {
 		t.Errorf("expected 1 image, got %d", len(images))
 	}
-	if images[0] != "/path/to/image.png" {
-		t.Errorf("expected image path %q, got %q", "/path/to/image.png", images[0])
+	if images[0] != imagePath {
+		t.Errorf("expected image path %q, got %q", imagePath, images[0])
 	}
 }
 
@@ -52,6 +67,39 @@ func TestNormalizeInput_EmptyImagePath(t *testing.T) {
 	}
 }
 
+func TestNormalizeInput_MissingImageFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.png")
+	_, _, err := normalizeInput(Compose(TextPart("Text"), ImagePart(missing)))
+	var invalidInput *ErrInvalidInput
+	if !errors.As(err, &invalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+	if invalidInput.Value != missing {
+		t.Errorf("expected value %q, got %q", missing, invalidInput.Value)
+	}
+	if !strings.Contains(invalidInput.Reason, "input part 1") {
+		t.Errorf("expected reason to name the input part, got %q", invalidInput.Reason)
+	}
+}
+
+func TestNormalizeInput_UnreadableImageFile(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for this user")
+	}
+	unreadable := filepath.Join(t.TempDir(), "secret.png")
+	if err := os.WriteFile(unreadable, []byte("png"), 0o000); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	_, _, err := normalizeInput(Compose(ImagePart(unreadable)))
+	var invalidInput *ErrInvalidInput
+	if !errors.As(err, &invalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+	if !strings.Contains(invalidInput.Reason, "not readable") {
+		t.Errorf("expected unreadable reason, got %q", invalidInput.Reason)
+	}
+}
+
 func TestNormalizeInput_MissingType(t *testing.T) {
 	input := Compose(
 		UserInput{}, // No type set
@@ -226,6 +274,109 @@ func TestUnmarshalThreadItem(t *testing.T) {
 	})
 }
 
+func TestUnmarshalFileChangeDiff(t *testing.T) {
+	data := `{"id":"4","type":"file_change","changes":[` +
+		`{"path":"a.go","kind":"update","diff":"--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-old\n+new\n"},` +
+		`{"path":"b.go","kind":"add"}],"status":"completed"}`
+	item, err := unmarshalThreadItem([]byte(data))
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	change, ok := item.(*FileChangeItem)
+	if !ok {
+		t.Fatalf("expected *FileChangeItem, got %T", item)
+	}
+	if len(change.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(change.Changes))
+	}
+	if want := "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-old\n+new\n"; change.Changes[0].Diff != want {
+		t.Errorf("expected diff %q, got %q", want, change.Changes[0].Diff)
+	}
+	if change.Changes[1].Diff != "" {
+		t.Errorf("expected no diff for change without one, got %q", change.Changes[1].Diff)
+	}
+}
+
+func TestUnmarshalWebSearchResults(t *testing.T) {
+	data := `{"id":"6","type":"web_search","query":"go generics","results":[` +
+		`{"url":"https://go.dev/doc/tutorial/generics","title":"Tutorial: Getting started with generics","snippet":"This tutorial introduces"},` +
+		`{"url":"https://go.dev/blog/intro-generics"}]}`
+	item, err := unmarshalThreadItem([]byte(data))
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	search, ok := item.(*WebSearchItem)
+	if !ok {
+		t.Fatalf("expected *WebSearchItem, got %T", item)
+	}
+	want := []WebSearchResult{
+		{URL: "https://go.dev/doc/tutorial/generics", Title: "Tutorial: Getting started with generics", Snippet: "This tutorial introduces"},
+		{URL: "https://go.dev/blog/intro-generics"},
+	}
+	if !reflect.DeepEqual(search.Results, want) {
+		t.Errorf("expected results %+v, got %+v", want, search.Results)
+	}
+
+	item, err = unmarshalThreadItem([]byte(`{"id":"7","type":"web_search","query":"golang"}`))
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if search := item.(*WebSearchItem); search.Query != "golang" || search.Results != nil {
+		t.Errorf("expected a search without results, got %+v", search)
+	}
+}
+
+func TestCheckUnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"known fields", `{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"hi"}}`, false},
+		{"unknown item type", `{"type":"item.completed","item":{"id":"1","type":"future","payload":{}}}`, false},
+		{"extra event field", `{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1},"latency_ms":5}`, true},
+		{"extra usage field", `{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1,"reasoning_tokens":2}}`, true},
+		{"extra item field", `{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"hi","phase":"final"}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event ThreadEvent
+			if err := json.Unmarshal([]byte(tt.data), &event); err != nil {
+				t.Fatalf("lenient unmarshal failed: %v", err)
+			}
+			err := checkUnknownFields([]byte(tt.data), event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRunDisallowUnknownFields(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"hi","phase":"final"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+
+	lenient, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if turn, err := lenient.StartThread().Run(testContext(t), Text("hello")); err != nil || turn.FinalResponse != "hi" {
+		t.Fatalf("expected lenient parsing to ignore the extra field, got %v", err)
+	}
+
+	strict, err := New(WithCodexPath(script), WithDisallowUnknownFields())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	_, err = strict.StartThread().Run(testContext(t), Text("hello"))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "phase"`) {
+		t.Fatalf("expected an unknown field error, got %v", err)
+	}
+}
+
 func TestOptionsApply(t *testing.T) {
 	// Test CodexOptions
 	opts := applyCodexOptions([]Option{
@@ -308,3 +459,540 @@ func TestTypeAliases(t *testing.T) {
 	var runStreamedResult *RunStreamedResult = streamedTurn
 	_ = runStreamedResult
 }
+
+func TestMcpContentBlockAccessors(t *testing.T) {
+	data := `{"id":"1","type":"mcp_tool_call","server":"s","tool":"t","status":"completed","result":{"content":[` +
+		`{"type":"text","text":"hello"},` +
+		`{"type":"image","data":"aGk=","mimeType":"image/png"},` +
+		`{"type":"resource","resource":{"uri":"file:///a.txt","mimeType":"text/plain","text":"contents"}}` +
+		`]}}`
+	item, err := unmarshalThreadItem([]byte(data))
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	call, ok := item.(*McpToolCallItem)
+	if !ok {
+		t.Fatalf("expected *McpToolCallItem, got %T", item)
+	}
+	if call.Result == nil || len(call.Result.Content) != 3 {
+		t.Fatalf("expected 3 content blocks, got %+v", call.Result)
+	}
+	textBlock, imageBlock, resourceBlock := call.Result.Content[0], call.Result.Content[1], call.Result.Content[2]
+
+	if text, ok := textBlock.AsText(); !ok || text != "hello" {
+		t.Errorf("AsText: expected (%q, true), got (%q, %t)", "hello", text, ok)
+	}
+	if _, _, ok := textBlock.AsImage(); ok {
+		t.Error("AsImage should not match a text block")
+	}
+
+	mimeType, img, ok := imageBlock.AsImage()
+	if !ok {
+		t.Fatal("AsImage: expected image block to decode")
+	}
+	if mimeType != "image/png" || string(img) != "hi" {
+		t.Errorf("AsImage: expected (image/png, %q), got (%s, %q)", "hi", mimeType, img)
+	}
+	if _, ok := imageBlock.AsText(); ok {
+		t.Error("AsText should not match an image block")
+	}
+
+	resource, ok := resourceBlock.AsResource()
+	if !ok {
+		t.Fatal("AsResource: expected resource block to decode")
+	}
+	if resource.URI != "file:///a.txt" || resource.MimeType != "text/plain" || resource.Text != "contents" {
+		t.Errorf("AsResource: unexpected resource %+v", resource)
+	}
+	if _, ok := textBlock.AsResource(); ok {
+		t.Error("AsResource should not match a text block")
+	}
+
+	invalid := McpContentBlock{Type: "image", Data: json.RawMessage(`"not base64!"`)}
+	if _, _, ok := invalid.AsImage(); ok {
+		t.Error("AsImage should fail for invalid base64 data")
+	}
+}
+
+func TestThreadEventTurnInfo(t *testing.T) {
+	var event ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"turn.started","turn_index":0,"model":"gpt-5-codex"}`), &event); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if event.TurnInfo == nil {
+		t.Fatal("expected turn info to be set")
+	}
+	if event.TurnInfo.Index == nil || *event.TurnInfo.Index != 0 {
+		t.Errorf("expected turn index 0, got %v", event.TurnInfo.Index)
+	}
+	if event.TurnInfo.Model != "gpt-5-codex" {
+		t.Errorf("expected model %q, got %q", "gpt-5-codex", event.TurnInfo.Model)
+	}
+
+	var partial ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"turn.started","model":"gpt-5"}`), &partial); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if partial.TurnInfo == nil || partial.TurnInfo.Index != nil || partial.TurnInfo.Model != "gpt-5" {
+		t.Errorf("expected only the model to be set, got %+v", partial.TurnInfo)
+	}
+
+	var bare ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"turn.started"}`), &bare); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if bare.TurnInfo != nil {
+		t.Errorf("expected nil turn info without metadata, got %+v", bare.TurnInfo)
+	}
+}
+
+func TestThreadEventMarshalRoundTrip(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"turn.started","turn_index":2,"model":"gpt-5"}`,
+		`{"type":"turn.completed","usage":{"input_tokens":10,"cached_input_tokens":2,"output_tokens":5}}`,
+		`{"type":"turn.failed","error":{"message":"boom"}}`,
+		`{"type":"error","message":"stream error"}`,
+		`{"type":"turn.failed","error":{"message":"too long","code":"context_length_exceeded"}}`,
+		`{"type":"error","message":"quota","code":"insufficient_quota"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"hi"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"reasoning","text":"thinking"}}`,
+		`{"type":"item.completed","item":{"id":"3","type":"command_execution","command":"ls","aggregated_output":"a\n","exit_code":0,"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"4","type":"file_change","changes":[{"path":"a.go","kind":"update"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"5","type":"mcp_tool_call","server":"s","tool":"t","arguments":{"q":1},"result":{"content":[{"type":"text","text":"ok"}]},"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"6","type":"web_search","query":"golang"}}`,
+		`{"type":"item.updated","item":{"id":"7","type":"todo_list","items":[{"text":"step","completed":true}]}}`,
+		`{"type":"item.completed","item":{"id":"8","type":"error","message":"oops"}}`,
+		`{"type":"item.completed","item":{"type":"future_type","data":"test"}}`,
+	}
+
+	for _, line := range lines {
+		var original ThreadEvent
+		if err := json.Unmarshal([]byte(line), &original); err != nil {
+			t.Fatalf("unmarshal %s failed: %v", line, err)
+		}
+
+		data, err := json.Marshal(original)
+		if err != nil {
+			t.Fatalf("marshal %s failed: %v", line, err)
+		}
+
+		var decoded ThreadEvent
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unmarshal re-encoded %s failed: %v", data, err)
+		}
+		original.rawItem, decoded.rawItem = nil, nil
+		if !reflect.DeepEqual(original, decoded) {
+			t.Errorf("round trip mismatch for %s:\n got: %#v\nwant: %#v", line, decoded, original)
+		}
+	}
+}
+
+func TestThreadEventErrorCodes(t *testing.T) {
+	var failed ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"turn.failed","error":{"message":"too long","code":"context_length_exceeded"}}`), &failed); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if failed.Error == nil || failed.Error.Code != "context_length_exceeded" || failed.Error.Message != "too long" {
+		t.Errorf("unexpected turn failure %+v", failed.Error)
+	}
+
+	var errEvent ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"error","message":"quota","code":"insufficient_quota"}`), &errEvent); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if errEvent.Code != "insufficient_quota" || errEvent.Message != "quota" {
+		t.Errorf("unexpected error event code=%q message=%q", errEvent.Code, errEvent.Message)
+	}
+
+	var legacy ThreadEvent
+	if err := json.Unmarshal([]byte(`{"type":"turn.failed","error":{"message":"boom"}}`), &legacy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if legacy.Error == nil || legacy.Error.Code != "" {
+		t.Errorf("expected empty code for error without one, got %+v", legacy.Error)
+	}
+}
+
+func TestThreadItemMarshalInjectsType(t *testing.T) {
+	data, err := json.Marshal(&AgentMessageItem{ID: "1", Text: "hi"})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	item, err := unmarshalThreadItem(data)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if _, ok := item.(*AgentMessageItem); !ok {
+		t.Fatalf("expected *AgentMessageItem, got %T", item)
+	}
+}
+
+func TestPrependContext(t *testing.T) {
+	if got := prependContext(nil, "prompt"); got != "prompt" {
+		t.Errorf("expected prompt unchanged without context, got %q", got)
+	}
+
+	opts := applyTurnOptions([]TurnOption{
+		WithContext("first"),
+		WithContext(""),
+		WithContext("second"),
+	})
+	got := prependContext(opts.Context, "Do the task")
+	want := "first\n\nsecond\n\n---\n\nDo the task"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCodexShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake codex shell scripts are not supported on windows")
+	}
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+exec sleep 30
+`)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx := testContext(t)
+	var streams []*StreamedTurn
+	for i := 0; i < 3; i++ {
+		streamed, err := client.StartThread().RunStreamed(ctx, Text("hello"))
+		if err != nil {
+			t.Fatalf("RunStreamed failed: %v", err)
+		}
+		<-streamed.Events // wait for thread.started so the process is running
+		streams = append(streams, streamed)
+	}
+
+	start := time.Now()
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected shutdown to terminate turns promptly, took %s", elapsed)
+	}
+
+	for i, streamed := range streams {
+		for range streamed.Events {
+		}
+		if err := streamed.Wait(); !errors.Is(err, ErrShutdown) {
+			t.Errorf("turn %d: expected ErrShutdown, got %v", i, err)
+		}
+	}
+
+	if _, err := client.StartThread().Run(ctx, Text("hello")); !errors.Is(err, ErrShutdown) {
+		t.Errorf("expected new turns to fail with ErrShutdown, got %v", err)
+	}
+}
+
+func TestCodexShutdownTerminatesGracefully(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake codex shell scripts are not supported on windows")
+	}
+	marker := filepath.Join(t.TempDir(), "terminated")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+trap 'kill $!; echo terminated > "`+marker+`"; exit 1' TERM
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+sleep 30 &
+wait
+`)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx := testContext(t)
+	streamed, err := client.StartThread().RunStreamed(ctx, Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	<-streamed.Events // wait for thread.started so the trap is installed
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("expected the CLI to handle SIGTERM before exiting: %v", err)
+	}
+	for range streamed.Events {
+	}
+	if err := streamed.Wait(); !errors.Is(err, ErrShutdown) {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+}
+
+func TestCodexShutdownIdle(t *testing.T) {
+	client, err := New(WithCodexPath("/custom/codex"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected shutdown without active turns to succeed, got %v", err)
+	}
+}
+
+func TestConcurrentThreads(t *testing.T) {
+	// Each fake turn echoes its prompt back, so crossed wires between
+	// concurrent turns show up as mismatched responses.
+	script := createFakeCodexShellScript(t, `prompt=$(cat)
+echo "{\"type\":\"thread.started\",\"thread_id\":\"thread-$prompt\"}"
+sleep 0.05
+echo "{\"type\":\"item.completed\",\"item\":{\"id\":\"1\",\"type\":\"agent_message\",\"text\":\"$prompt\"}}"
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	env := map[string]string{"PATH": os.Getenv("PATH")}
+	client, err := New(WithCodexPath(script), WithEnv(env))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	mutated := make(chan struct{})
+	go func() {
+		// Mutating the caller's map must not race with running turns.
+		defer close(mutated)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				env["SDK_TEST_COUNTER"] = strconv.Itoa(i)
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	errs := make(chan error, 4)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		thread := client.StartThread(WithModel("model-" + name))
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				turn, err := thread.Run(testContext(t), Text(name))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if turn.FinalResponse != name || thread.ID() != "thread-"+name {
+					errs <- fmt.Errorf("thread %s got response %q and ID %q", name, turn.FinalResponse, thread.ID())
+					return
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+	close(stop)
+	<-mutated
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPromptTemplate(t *testing.T) {
+	input, err := PromptTemplate("Fix {{.File}}{{range .Tests}} {{.}}{{end}}", map[string]any{
+		"File":  "parser.go",
+		"Tests": []string{"TestA", "TestB"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prompt, _, err := normalizeInput(input)
+	if err != nil {
+		t.Fatalf("normalizeInput failed: %v", err)
+	}
+	if want := "Fix parser.go TestA TestB"; prompt != want {
+		t.Errorf("expected prompt %q, got %q", want, prompt)
+	}
+
+	errorCases := []struct {
+		name string
+		tmpl string
+		vars map[string]any
+		want string
+	}{
+		{"missing_var", "Fix {{.File}}", map[string]any{"Other": 1}, "render prompt template"},
+		{"nil_vars", "Fix {{.File}}", nil, "render prompt template"},
+		{"parse_error", "Fix {{.File", map[string]any{"File": "x"}, "parse prompt template"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PromptTemplate(tt.tmpl, tt.vars)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	running := t.TempDir()
+	countsFile := filepath.Join(t.TempDir(), "counts.txt")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+touch '`+running+`'/$$
+ls '`+running+`' | wc -l >> '`+countsFile+`'
+sleep 0.2
+rm '`+running+`'/$$
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	client, err := New(WithCodexPath(script), WithMaxConcurrency(2))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.StartThread().Run(testContext(t), Text("hello")); err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(countsFile)
+	if err != nil {
+		t.Fatalf("failed to read counts: %v", err)
+	}
+	counts := strings.Fields(string(data))
+	if len(counts) != 6 {
+		t.Fatalf("expected 6 recorded turns, got %q", counts)
+	}
+	for _, c := range counts {
+		if n, _ := strconv.Atoi(c); n > 2 {
+			t.Errorf("expected at most 2 concurrent processes, observed %d", n)
+		}
+	}
+}
+
+func TestMaxConcurrencyWaitRespectsContext(t *testing.T) {
+	script := createFakeCodexShellScript(t, `delay=$(cat)
+sleep "$delay"
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	client, err := New(WithCodexPath(script), WithMaxConcurrency(1))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	longCtx, cancelLong := context.WithCancel(testContext(t))
+	defer cancelLong()
+	long, err := client.StartThread().RunStreamed(longCtx, Text("5"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(testContext(t), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.StartThread().Run(ctx, Text("0")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline while waiting for a slot, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected waiting turn to give up promptly, took %s", elapsed)
+	}
+
+	cancelLong()
+	for range long.Events {
+	}
+	_ = long.Wait()
+
+	if _, err := client.StartThread().Run(testContext(t), Text("0")); err != nil {
+		t.Errorf("expected slot to be released after the long turn, got %v", err)
+	}
+}
+
+func TestInputFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prompt.md")
+	if err := os.WriteFile(path, []byte("Refactor the parser.\n"), 0o644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+
+	input, err := InputFromFile(path)
+	if err != nil {
+		t.Fatalf("InputFromFile failed: %v", err)
+	}
+	prompt, _, err := normalizeInput(input)
+	if err != nil {
+		t.Fatalf("normalizeInput failed: %v", err)
+	}
+	if prompt != "Refactor the parser.\n" {
+		t.Errorf("expected file contents as prompt, got %q", prompt)
+	}
+
+	if _, err := InputFromFileLimit(path, 21); err != nil {
+		t.Errorf("expected file at the limit to be accepted, got %v", err)
+	}
+
+	var invalidInput *ErrInvalidInput
+	if _, err := InputFromFileLimit(path, 20); !errors.As(err, &invalidInput) || !strings.Contains(invalidInput.Reason, "20 byte limit") {
+		t.Errorf("expected size limit error, got %v", err)
+	}
+	if _, err := InputFromFile(filepath.Join(dir, "missing.md")); !errors.As(err, &invalidInput) || invalidInput.Reason != "path does not exist" {
+		t.Errorf("expected missing file error, got %v", err)
+	}
+}
+
+func TestCodexPing(t *testing.T) {
+	script := createFakeCodexShellScript(t, `if [ "$1" = "--version" ]; then
+  echo "codex-cli 0.50.0"
+  exit 0
+fi
+exit 1
+`)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := client.Ping(testContext(t)); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+}
+
+func TestCodexPingFailure(t *testing.T) {
+	script := createFakeCodexShellScript(t, `echo "error while loading shared libraries" >&2
+exit 127
+`)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	err = client.Ping(testContext(t))
+	var execErr *ErrExecFailed
+	if !errors.As(err, &execErr) || execErr.ExitCode != 127 {
+		t.Fatalf("expected ErrExecFailed with exit code 127, got %v", err)
+	}
+}
+
+func TestCodexPingNotExecutable(t *testing.T) {
+	script := createFakeCodexShellScript(t, "exit 0\n")
+	if err := os.Chmod(script, 0o644); err != nil {
+		t.Fatalf("chmod failed: %v", err)
+	}
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := client.Ping(testContext(t)); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected a permission error, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package codex
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// configTOMLOverrides converts the TOML document set by WithRawConfigTOML
+// into --config overrides. The CLI has no flag for reading an extra config
+// file, so each key is passed as dotted.key=value with the value kept in its
+// TOML form.
+func configTOMLOverrides(doc string) ([]string, error) {
+	if strings.TrimSpace(doc) == "" {
+		return nil, nil
+	}
+	overrides, err := parseConfigTOML(doc)
+	if err != nil {
+		return nil, &ErrInvalidInput{
+			Field:  "raw config TOML",
+			Value:  doc,
+			Reason: err.Error(),
+		}
+	}
+	return overrides, nil
+}
+
+// parseConfigTOML parses the subset of TOML that maps onto --config
+// overrides, one line at a time: comments, [table] headers and key = value
+// pairs whose value, including any array or inline table, fits on one line.
+// Arrays of tables and multi-line strings, arrays and inline tables are
+// valid TOML but are rejected with an error that names the construct.
+func parseConfigTOML(doc string) ([]string, error) {
+	var (
+		overrides []string
+		table     string
+		seen      = make(map[string]bool)
+	)
+	for n, text := range strings.Split(doc, "\n") {
+		p := &tomlParser{s: strings.TrimSuffix(text, "\r")}
+		p.skipSpace()
+		if p.done() || p.peek() == '#' {
+			continue
+		}
+
+		if p.peek() == '[' {
+			p.pos++
+			if !p.done() && p.peek() == '[' {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported; use an array of inline tables on one line", n+1)
+			}
+			p.skipSpace()
+			key, err := p.key()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			p.skipSpace()
+			if err := p.expect(']'); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			if err := p.end(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			table = key
+			continue
+		}
+
+		key, err := p.key()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		p.skipSpace()
+		if err := p.expect('='); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		p.skipSpace()
+		start := p.pos
+		if err := p.value(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		value := p.s[start:p.pos]
+		if err := p.end(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		if table != "" {
+			key = table + "." + key
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n+1, key)
+		}
+		seen[key] = true
+		overrides = append(overrides, key+"="+value)
+	}
+	return overrides, nil
+}
+
+var (
+	tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+	tomlScalar  = regexp.MustCompile(`^[^\s,\]}#]+`)
+	tomlNumber  = regexp.MustCompile(`^[+-]?(0x[0-9A-Fa-f_]+|0o[0-7_]+|0b[01_]+|[0-9][0-9_]*(\.[0-9][0-9_]*)?([eE][+-]?[0-9][0-9_]*)?|inf|nan)$`)
+	tomlTime    = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}([Tt][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[+-][0-9]{2}:[0-9]{2})?)?|[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?)$`)
+
+	errTOMLEndOfLine = errors.New("unexpected end of line; multi-line arrays and inline tables are not supported, write the value on one line")
+)
+
+// tomlParser scans a single line of a TOML document.
+type tomlParser struct {
+	s   string
+	pos int
+}
+
+func (p *tomlParser) done() bool { return p.pos >= len(p.s) }
+func (p *tomlParser) peek() byte { return p.s[p.pos] }
+
+func (p *tomlParser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) expect(c byte) error {
+	if p.done() {
+		return fmt.Errorf("expected %q, got end of line", c)
+	}
+	if p.peek() != c {
+		return fmt.Errorf("expected %q, got %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// end checks that only whitespace or a comment remains on the line.
+func (p *tomlParser) end() error {
+	p.skipSpace()
+	if p.done() || p.peek() == '#' {
+		return nil
+	}
+	return fmt.Errorf("unexpected %q after value", p.s[p.pos:])
+}
+
+// key parses a dotted key and returns it with whitespace around dots removed.
+func (p *tomlParser) key() (string, error) {
+	var parts []string
+	for {
+		start := p.pos
+		if p.done() {
+			return "", errors.New("expected key, got end of line")
+		}
+		switch p.peek() {
+		case '"', '\'':
+			if err := p.str(); err != nil {
+				return "", err
+			}
+		default:
+			n := len(tomlBareKey.FindString(p.s[p.pos:]))
+			if n == 0 {
+				return "", fmt.Errorf("invalid key at %q", p.s[p.pos:])
+			}
+			p.pos += n
+		}
+		parts = append(parts, p.s[start:p.pos])
+
+		p.skipSpace()
+		if p.done() || p.peek() != '.' {
+			return strings.Join(parts, "."), nil
+		}
+		p.pos++
+		p.skipSpace()
+	}
+}
+
+// value parses a single-line TOML value.
+func (p *tomlParser) value() error {
+	if p.done() {
+		return errors.New("expected value, got end of line")
+	}
+	switch p.peek() {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		p.pos++
+		return p.list(']', func() error { return p.value() })
+	case '{':
+		p.pos++
+		return p.list('}', func() error {
+			if _, err := p.key(); err != nil {
+				return err
+			}
+			p.skipSpace()
+			if err := p.expect('='); err != nil {
+				return err
+			}
+			p.skipSpace()
+			return p.value()
+		})
+	}
+
+	scalar := tomlScalar.FindString(p.s[p.pos:])
+	switch {
+	case scalar == "true", scalar == "false", tomlNumber.MatchString(scalar), tomlTime.MatchString(scalar):
+		p.pos += len(scalar)
+		return nil
+	case scalar == "":
+		return fmt.Errorf("expected value at %q", p.s[p.pos:])
+	default:
+		return fmt.Errorf("invalid value %q", scalar)
+	}
+}
+
+// list parses comma-separated elements up to the closing delimiter. Inline
+// tables do not allow a trailing comma, but arrays do; both are accepted.
+func (p *tomlParser) list(closing byte, element func() error) error {
+	for {
+		p.skipSpace()
+		if p.done() {
+			return errTOMLEndOfLine
+		}
+		if p.peek() == closing {
+			p.pos++
+			return nil
+		}
+		if err := element(); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.done() {
+			return errTOMLEndOfLine
+		}
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		return p.expect(closing)
+	}
+}
+
+// str parses a basic or literal string.
+func (p *tomlParser) str() error {
+	quote := p.peek()
+	if strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3)) {
+		return errors.New(`multi-line strings are not supported; use a single-line string with \n escapes`)
+	}
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch p.s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			p.pos = i + 1
+			return nil
+		}
+	}
+	return errors.New("unterminated string")
+}
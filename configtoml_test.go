@@ -0,0 +1,115 @@
+package codex
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigTOML(t *testing.T) {
+	doc := `# provider settings
+model_provider = "azure" # trailing comment
+model_reasoning_summary='detailed'
+
+[model_providers.azure]
+name = "Azure \"OpenAI\""
+base_url = "https://example.openai.azure.com/openai"
+query_params = { api-version = "2025-04-01-preview" }
+env_http_headers = { headers.org = "OPENAI_ORG" }
+
+[ mcp_servers . docs ]
+args = ["-y", "docs-server", ]
+startup_timeout_sec = 1_0
+tool_timeout_sec = 2.5e1
+enabled = true
+`
+	want := []string{
+		`model_provider="azure"`,
+		`model_reasoning_summary='detailed'`,
+		`model_providers.azure.name="Azure \"OpenAI\""`,
+		`model_providers.azure.base_url="https://example.openai.azure.com/openai"`,
+		`model_providers.azure.query_params={ api-version = "2025-04-01-preview" }`,
+		`model_providers.azure.env_http_headers={ headers.org = "OPENAI_ORG" }`,
+		`mcp_servers.docs.args=["-y", "docs-server", ]`,
+		`mcp_servers.docs.startup_timeout_sec=1_0`,
+		`mcp_servers.docs.tool_timeout_sec=2.5e1`,
+		`mcp_servers.docs.enabled=true`,
+	}
+
+	got, err := parseConfigTOML(doc)
+	if err != nil {
+		t.Fatalf("parseConfigTOML failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseConfigTOMLInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing equals":     `model "gpt-5"`,
+		"bare string value":  `model = gpt-5`,
+		"unterminated":       `model = "gpt-5`,
+		"unclosed table":     "[features\nweb_search_request = true",
+		"trailing garbage":   `model = "gpt-5" "o3"`,
+		"duplicate key":      "[a]\nb = 1\n[a]\nb = 2",
+		"empty key":          `= 1`,
+		"missing array item": `args = ["x" "y"]`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got, err := parseConfigTOML(doc); err == nil {
+				t.Errorf("expected an error for %q, got %q", doc, got)
+			}
+		})
+	}
+}
+
+func TestParseConfigTOMLUnsupported(t *testing.T) {
+	tests := map[string]struct {
+		doc  string
+		want string
+	}{
+		"multi-line array":        {doc: "args = [\n  \"x\",\n]", want: "multi-line arrays and inline tables are not supported"},
+		"multi-line inline table": {doc: "env = {\n  A = \"1\" }", want: "multi-line arrays and inline tables are not supported"},
+		"multi-line string":       {doc: `instructions = """hi"""`, want: "multi-line strings are not supported"},
+		"array of tables":         {doc: "[[profiles]]\nmodel = \"o3\"", want: "arrays of tables are not supported"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseConfigTOML(tt.doc)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRunRawConfigTOML(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithRawConfigTOML("[features]\nweb_search_request = true\n"))
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if args := readRecordedArgs(t, argsFile); !containsArgPair(args, "--config", "features.web_search_request=true") {
+		t.Errorf("expected config override in args, got %q", args)
+	}
+}
+
+func TestRunRawConfigTOMLInvalid(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithRawConfigTOML("[features\nweb_search_request = true\n"))
+
+	_, err := thread.Run(testContext(t), Text("hello"))
+	var invalid *ErrInvalidInput
+	if !errors.As(err, &invalid) || !strings.Contains(invalid.Reason, "line 1") {
+		t.Fatalf("expected ErrInvalidInput pointing at line 1, got %v", err)
+	}
+	if _, err := os.Stat(argsFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected codex not to run, got stat error %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package codex
+
+// PriceTable holds model prices in dollars per million tokens.
+type PriceTable struct {
+	// InputPerMillion is the price of uncached input tokens.
+	InputPerMillion float64
+	// CachedInputPerMillion is the discounted price of cached input tokens.
+	// When nil, cached tokens are priced at InputPerMillion; point it at zero
+	// for models whose cached input is free.
+	CachedInputPerMillion *float64
+	// OutputPerMillion is the price of output tokens, including reasoning.
+	OutputPerMillion float64
+}
+
+// EstimateCost returns the estimated dollar cost of the usage at the given
+// prices. Cached input tokens are a subset of InputTokens and are charged at
+// the cached rate instead of the input rate.
+func (u Usage) EstimateCost(prices PriceTable) float64 {
+	cachedRate := prices.InputPerMillion
+	if prices.CachedInputPerMillion != nil {
+		cachedRate = *prices.CachedInputPerMillion
+	}
+
+	cached := min(u.CachedInputTokens, u.InputTokens)
+	uncached := u.InputTokens - cached
+	return (float64(uncached)*prices.InputPerMillion +
+		float64(cached)*cachedRate +
+		float64(u.OutputTokens)*prices.OutputPerMillion) / 1e6
+}
@@ -0,0 +1,34 @@
+package codex
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUsageEstimateCost(t *testing.T) {
+	cachedRate := 0.125
+	prices := PriceTable{InputPerMillion: 1.25, CachedInputPerMillion: &cachedRate, OutputPerMillion: 10}
+
+	tests := []struct {
+		name   string
+		usage  Usage
+		prices PriceTable
+		want   float64
+	}{
+		{"zero", Usage{}, prices, 0},
+		{"uncached", Usage{InputTokens: 1_000_000, OutputTokens: 100_000}, prices, 1.25 + 1},
+		{"cached_discount", Usage{InputTokens: 1_000_000, CachedInputTokens: 800_000, OutputTokens: 0}, prices, 0.2*1.25 + 0.8*0.125},
+		{"small_turn", Usage{InputTokens: 12_000, CachedInputTokens: 4_000, OutputTokens: 1_500}, prices, (8_000*1.25 + 4_000*0.125 + 1_500*10) / 1e6},
+		{"cached_rate_unset", Usage{InputTokens: 2_000_000, CachedInputTokens: 1_000_000}, PriceTable{InputPerMillion: 2}, 4},
+		{"cached_free", Usage{InputTokens: 2_000_000, CachedInputTokens: 1_000_000}, PriceTable{InputPerMillion: 2, CachedInputPerMillion: new(float64)}, 2},
+		{"cached_exceeds_input", Usage{InputTokens: 100, CachedInputTokens: 500}, prices, 100 * 0.125 / 1e6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usage.EstimateCost(tt.prices); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("EstimateCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
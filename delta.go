@@ -0,0 +1,42 @@
+package codex
+
+import "sync"
+
+// messageAccumulator assembles agent message text from the deltas carried by
+// item.updated events.
+type messageAccumulator struct {
+	mu    sync.Mutex
+	texts map[string]string
+}
+
+// apply records msg and fills in its Text. A delta without text is appended
+// to the message's text so far; text sent by the CLI replaces it. An event
+// carrying neither, such as an item.completed sent after a stream of deltas,
+// gets the assembled text.
+func (a *messageAccumulator) apply(msg *AgentMessageItem) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.texts == nil {
+		a.texts = make(map[string]string)
+	}
+
+	switch {
+	case msg.Text != "":
+		a.texts[msg.ID] = msg.Text
+	case msg.Delta != "":
+		a.texts[msg.ID] += msg.Delta
+		msg.Text = a.texts[msg.ID]
+	default:
+		msg.Text = a.texts[msg.ID]
+	}
+}
+
+// text returns the text assembled so far for the message with the given ID.
+func (a *messageAccumulator) text(id string) string {
+	if a == nil {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.texts[id]
+}
@@ -0,0 +1,69 @@
+package codex
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRunStreamedAccumulatesDeltas(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.started","item":{"id":"m","type":"agent_message","text":""}}`,
+		`{"type":"item.updated","item":{"id":"m","type":"agent_message","delta":"Hello"}}`,
+		`{"type":"item.updated","item":{"id":"m","type":"agent_message","delta":", "}}`,
+		`{"type":"item.updated","item":{"id":"m","type":"agent_message","delta":"world"}}`,
+		`{"type":"item.completed","item":{"id":"m","type":"agent_message","text":""}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	var (
+		deltas   []string
+		partials []string
+		final    string
+	)
+	for event := range streamed.Events {
+		msg, ok := event.Item.(*AgentMessageItem)
+		if !ok {
+			continue
+		}
+		switch event.Type {
+		case EventItemUpdated:
+			deltas = append(deltas, msg.Delta)
+			partials = append(partials, msg.Text)
+		case EventItemCompleted:
+			final = msg.Text
+		}
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	if want := []string{"Hello", ", ", "world"}; !slices.Equal(deltas, want) {
+		t.Errorf("expected deltas %q, got %q", want, deltas)
+	}
+	if want := []string{"Hello", "Hello, ", "Hello, world"}; !slices.Equal(partials, want) {
+		t.Errorf("expected assembled text %q, got %q", want, partials)
+	}
+	if final != "Hello, world" || streamed.MessageText("m") != final {
+		t.Errorf("expected completed text to match the deltas, got %q and %q", final, streamed.MessageText("m"))
+	}
+}
+
+func TestMessageAccumulatorPrefersCLIText(t *testing.T) {
+	var acc messageAccumulator
+	acc.apply(&AgentMessageItem{ID: "m", Delta: "draft"})
+
+	completed := &AgentMessageItem{ID: "m", Text: "final answer"}
+	acc.apply(completed)
+	if completed.Text != "final answer" || acc.text("m") != "final answer" {
+		t.Errorf("expected text from the CLI to win, got %q and %q", completed.Text, acc.text("m"))
+	}
+	if got := acc.text("other"); got != "" {
+		t.Errorf("expected no text for an unknown message, got %q", got)
+	}
+}
@@ -83,6 +83,14 @@
 //	thread := client.ResumeThread(savedThreadID)
 //	turn, err := thread.Run(ctx, codex.Text("Continue the conversation"))
 //
+// # Concurrency
+//
+// A Codex client and its threads are safe for concurrent use. Every turn
+// starts its own codex process, and the client only holds configuration that
+// is fixed when it is created, so turns on different threads may run in
+// parallel. Turns on the same thread should run one after another: each turn
+// resumes the conversation left by the previous one.
+//
 // # Configuration
 //
 // Configure the client with functional options:
@@ -0,0 +1,126 @@
+package codex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// loadEnvFile reads a dotenv file for WithEnvFile.
+func loadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &ErrInvalidInput{
+			Field:  "env file",
+			Value:  path,
+			Reason: "cannot open file: " + err.Error(),
+		}
+	}
+	defer f.Close()
+
+	env, err := parseEnvFile(f)
+	if err != nil {
+		return nil, &ErrInvalidInput{
+			Field:  "env file",
+			Value:  path,
+			Reason: err.Error(),
+		}
+	}
+	return env, nil
+}
+
+// parseEnvFile parses dotenv content. Each non-blank line that is not a #
+// comment holds KEY=VALUE, optionally prefixed with "export ". Values may be
+// single-quoted (taken literally), double-quoted (with \n, \t, \", \\
+// escapes), or unquoted, in which case a " #" starts a trailing comment and
+// surrounding whitespace is trimmed.
+func parseEnvFile(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !isEnvKey(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNo, key)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// parseEnvValue decodes the value part of a dotenv line.
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	quote := raw[0]
+	if quote != '"' && quote != '\'' {
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == quote:
+			rest := strings.TrimSpace(raw[i+1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected text after closing quote: %q", rest)
+			}
+			return b.String(), nil
+		case c == '\\' && quote == '"' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(raw[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated %c quote", quote)
+}
+
+// isEnvKey reports whether key is a valid environment variable name.
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
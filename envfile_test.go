@@ -0,0 +1,121 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	content := `# local settings
+OPENAI_BASE_URL=https://proxy.example.com
+export REGION = us-east-1
+
+EMPTY=
+TRAILING=value # comment
+HASH=a#b
+DOUBLE="line one\nsaid \"hi\"" # comment
+SINGLE='literal \n $HOME'
+SPACED="  padded  "
+`
+	got, err := parseEnvFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parseEnvFile failed: %v", err)
+	}
+	want := map[string]string{
+		"OPENAI_BASE_URL": "https://proxy.example.com",
+		"REGION":          "us-east-1",
+		"EMPTY":           "",
+		"TRAILING":        "value",
+		"HASH":            "a#b",
+		"DOUBLE":          "line one\nsaid \"hi\"",
+		"SINGLE":          `literal \n $HOME`,
+		"SPACED":          "  padded  ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseEnvFileMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing equals", "FOO=bar\nNOVALUE\n", "line 2: missing '='"},
+		{"invalid name", "1FOO=bar", "line 1: invalid variable name"},
+		{"empty name", "=bar", "line 1: invalid variable name"},
+		{"unterminated quote", `FOO="bar`, "line 1: unterminated \" quote"},
+		{"text after quote", `FOO='bar' baz`, "line 1: unexpected text after closing quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEnvFile(strings.NewReader(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWithEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("FROM_FILE=file\nSHARED=file\n"), 0o600); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+
+	t.Run("inherited environment", func(t *testing.T) {
+		t.Setenv("SHARED", "process")
+		client, err := New(WithCodexPath("/custom/codex"), WithEnvFile(path))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		env := client.exec.buildEnvironment(context.Background(), "", "")
+		if !containsString(env, "FROM_FILE=file") || !containsString(env, "SHARED=file") {
+			t.Errorf("expected file variables over the inherited environment, got %q", env)
+		}
+		if !containsString(env, "PATH="+os.Getenv("PATH")) {
+			t.Errorf("expected os.Environ to still be inherited, got %q", env)
+		}
+	})
+
+	t.Run("with env map", func(t *testing.T) {
+		client, err := New(
+			WithCodexPath("/custom/codex"),
+			WithEnv(map[string]string{"SHARED": "map"}),
+			WithEnvFile(path),
+		)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		env := client.exec.buildEnvironment(context.Background(), "", "")
+		if !containsString(env, "FROM_FILE=file") || !containsString(env, "SHARED=map") {
+			t.Errorf("expected WithEnv to win over the file, got %q", env)
+		}
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), ".env")
+		if err := os.WriteFile(bad, []byte("NOT A LINE\n"), 0o600); err != nil {
+			t.Fatalf("failed to write env file: %v", err)
+		}
+		_, err := New(WithCodexPath("/custom/codex"), WithEnvFile(bad))
+		var invalid *ErrInvalidInput
+		if !errors.As(err, &invalid) || invalid.Field != "env file" {
+			t.Errorf("expected *ErrInvalidInput for env file, got %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := New(WithCodexPath("/custom/codex"), WithEnvFile(filepath.Join(t.TempDir(), "missing")))
+		var invalid *ErrInvalidInput
+		if !errors.As(err, &invalid) {
+			t.Errorf("expected *ErrInvalidInput for a missing file, got %v", err)
+		}
+	})
+}
@@ -3,11 +3,57 @@ package codex
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // ErrCodexNotFound is returned when the codex binary cannot be found.
 var ErrCodexNotFound = errors.New("codex binary not found in PATH or bundled location")
 
+// ErrChecksumMismatch is returned when the codex binary does not match the
+// digest configured with WithBinaryChecksum.
+var ErrChecksumMismatch = errors.New("codex binary checksum mismatch")
+
+// ErrIdleTimeout is returned when a turn receives no event within the idle
+// timeout configured with WithIdleTimeout.
+var ErrIdleTimeout = errors.New("codex turn idle timeout exceeded")
+
+// ErrStartupTimeout is returned when the CLI emits no event within the
+// startup timeout configured with WithStartupTimeout.
+var ErrStartupTimeout = errors.New("codex startup timeout exceeded")
+
+// ErrShutdown is returned for turns cancelled by, or started after, Codex.Shutdown.
+var ErrShutdown = errors.New("codex client is shut down")
+
+// ErrInterrupted is returned by StreamedTurn.Wait for turns stopped by
+// StreamedTurn.Interrupt.
+var ErrInterrupted = errors.New("codex turn interrupted")
+
+// ErrThreadIDMismatch is returned when the CLI reports a thread ID that
+// differs from the thread's existing ID and WithStrictThreadID is set.
+var ErrThreadIDMismatch = errors.New("codex thread ID mismatch")
+
+// ErrInvalidEventSequence is returned when the CLI's events violate the
+// protocol's ordering invariants. See ValidateEventSequence.
+var ErrInvalidEventSequence = errors.New("codex event sequence is invalid")
+
+// ErrSessionNotFound is returned, wrapping the exec failure, when the CLI
+// cannot find or load the session a thread resumes. Detection relies on the
+// CLI's stderr.
+var ErrSessionNotFound = errors.New("codex session not found")
+
+// ErrContextLengthExceeded is returned, wrapping the underlying failure, when
+// the prompt and conversation history exceed the model's context window.
+// Callers can shorten the input or start a new thread and retry.
+var ErrContextLengthExceeded = errors.New("codex context length exceeded")
+
+// ErrModelUnavailable is returned, wrapping the underlying failure, when the
+// CLI or the backend reports that the requested model does not exist or
+// cannot be used. Detection relies on the error code or message.
+var ErrModelUnavailable = errors.New("codex model unavailable")
+
+// ErrNoSessions is returned by ResumeLatest when no persisted session exists.
+var ErrNoSessions = errors.New("no codex sessions found")
+
 // ErrInvalidInput represents an error caused by invalid user input.
 type ErrInvalidInput struct {
 	// Field is the name of the field that failed validation.
@@ -48,3 +94,61 @@ func (e *ErrExecFailed) Error() string {
 func (e *ErrExecFailed) Unwrap() error {
 	return e.Err
 }
+
+// ErrRateLimited is returned when the CLI fails because the backend rate
+// limited the request. It wraps the underlying *ErrExecFailed.
+type ErrRateLimited struct {
+	// RetryAfter is the delay suggested by the backend, or zero if the CLI
+	// output did not include one.
+	RetryAfter time.Duration
+	// Err is the underlying exec failure.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ErrRateLimited) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("codex rate limited (retry after %s): %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("codex rate limited: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrRateLimited) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnsupportedPlatform reports that no bundled codex binary exists for the
+// platform. It is wrapped, together with ErrCodexNotFound, when codex is also
+// missing from PATH.
+type ErrUnsupportedPlatform struct {
+	// GOOS is the operating system the SDK was built for, as in runtime.GOOS.
+	GOOS string
+	// GOARCH is the architecture the SDK was built for, as in runtime.GOARCH.
+	GOARCH string
+}
+
+// Error implements the error interface.
+func (e *ErrUnsupportedPlatform) Error() string {
+	return fmt.Sprintf("no bundled codex binary for unsupported platform %s/%s", e.GOOS, e.GOARCH)
+}
+
+// ErrUnsupportedFlag is returned when the installed CLI rejects a flag the
+// SDK passed to it, which usually means the CLI is older than the SDK
+// expects. It wraps the underlying *ErrExecFailed.
+type ErrUnsupportedFlag struct {
+	// Flag is the rejected flag as reported by the CLI.
+	Flag string
+	// Err is the underlying exec failure.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ErrUnsupportedFlag) Error() string {
+	return fmt.Sprintf("codex CLI does not support %s, upgrade the CLI: %v", e.Flag, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrUnsupportedFlag) Unwrap() error {
+	return e.Err
+}
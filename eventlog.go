@@ -0,0 +1,92 @@
+package codex
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// eventLog writes the raw JSONL events of a client's turns to a gzip file
+// for WithCompressedEventLog. Each turn ends the current gzip member, so the
+// file is a valid multi-member gzip stream after every turn, even one that
+// failed. Lines from concurrent turns may interleave but are never split.
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	// err is the first write failure; later writes are dropped.
+	err error
+}
+
+// openEventLog creates or truncates the log file at path.
+func openEventLog(path string) (*eventLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create event log: %w", err)
+	}
+	return &eventLog{file: file}, nil
+}
+
+// write appends one event line. A nil log discards it.
+func (l *eventLog) write(line []byte) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil || l.file == nil {
+		return
+	}
+	if l.gz == nil {
+		l.gz = gzip.NewWriter(l.file)
+	}
+	if _, err := l.gz.Write(line); err != nil {
+		l.err = err
+		return
+	}
+	if _, err := l.gz.Write([]byte{'\n'}); err != nil {
+		l.err = err
+	}
+}
+
+// endTurn finishes the current gzip member so the file is complete on disk.
+func (l *eventLog) endTurn() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeMember()
+}
+
+func (l *eventLog) closeMember() {
+	if l.gz == nil {
+		return
+	}
+	if err := l.gz.Close(); err != nil && l.err == nil {
+		l.err = err
+	}
+	l.gz = nil
+}
+
+// close finishes the log and closes the file, returning the first write
+// failure, if any.
+func (l *eventLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return l.err
+	}
+	l.closeMember()
+	closeErr := l.file.Close()
+	l.file = nil
+	if err := errors.Join(l.err, closeErr); err != nil {
+		return fmt.Errorf("write event log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package codex
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithCompressedEventLog(t *testing.T) {
+	okLines := []string{
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"done"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	}
+	failLines := []string{
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.failed","error":{"message":"boom"}}`,
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "events.jsonl.gz")
+
+	readLog := func(t *testing.T) string {
+		t.Helper()
+		f, err := os.Open(logPath)
+		if err != nil {
+			t.Fatalf("failed to open log: %v", err)
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("log is not valid gzip: %v", err)
+		}
+		data, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("failed to decompress log: %v", err)
+		}
+		return string(data)
+	}
+
+	client, err := New(WithCodexPath(createFakeCodexEventsScript(t, okLines...)), WithCompressedEventLog(logPath))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := client.StartThread().Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := strings.Join(okLines, "\n") + "\n"
+	if got := readLog(t); got != want {
+		t.Errorf("expected log after first turn\n%s\ngot\n%s", want, got)
+	}
+
+	client.exec.path = createFakeCodexEventsScript(t, failLines...)
+	if _, err := client.StartThread().Run(testContext(t), Text("hello")); err == nil {
+		t.Fatal("expected the second turn to fail")
+	}
+	want += strings.Join(failLines, "\n") + "\n"
+	if got := readLog(t); got != want {
+		t.Errorf("expected log after failed turn\n%s\ngot\n%s", want, got)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := readLog(t); got != want {
+		t.Errorf("expected log to be unchanged by Close\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestWithCompressedEventLogInvalidPath(t *testing.T) {
+	_, err := New(WithCodexPath("/custom/codex"), WithCompressedEventLog(filepath.Join(t.TempDir(), "missing", "events.gz")))
+	if err == nil || !strings.Contains(err.Error(), "create event log") {
+		t.Errorf("expected create event log error, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package codex
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"time"
 )
 
 // EventType enumerates the JSON events emitted by codex exec.
@@ -37,10 +40,21 @@ type Usage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// TurnInfo carries the metadata a turn.started event reports about the turn.
+type TurnInfo struct {
+	// Index is the position of the turn within the thread, when reported.
+	Index *int `json:"turn_index,omitempty"`
+	// Model is the model that runs the turn, after any family or fallback
+	// resolution by the CLI. Empty when not reported.
+	Model string `json:"model,omitempty"`
+}
+
 // ThreadError describes a fatal error emitted by a turn.
 type ThreadError struct {
 	// Message contains the error description.
 	Message string `json:"message"`
+	// Code is a machine-readable error code, when the CLI reports one.
+	Code string `json:"code,omitempty"`
 }
 
 // ThreadEvent represents a single line event emitted by codex exec.
@@ -49,6 +63,9 @@ type ThreadEvent struct {
 	Type EventType `json:"type"`
 	// ThreadID is populated on thread.started events.
 	ThreadID string `json:"thread_id,omitempty"`
+	// TurnInfo is populated on turn.started events that carry metadata about
+	// the turn. It is nil when the CLI reports none.
+	TurnInfo *TurnInfo `json:"-"`
 	// Usage is populated on turn.completed events.
 	Usage *Usage `json:"usage,omitempty"`
 	// Error is populated on turn.failed events.
@@ -57,6 +74,12 @@ type ThreadEvent struct {
 	Item ThreadItem `json:"-"`
 	// Message is populated on top-level error events.
 	Message string `json:"message,omitempty"`
+	// Code is the machine-readable code of a top-level error event, when
+	// the CLI reports one.
+	Code string `json:"code,omitempty"`
+	// ReceivedAt is when the SDK read the event from the CLI. It is set by
+	// the streaming loop and is not part of the JSON payload.
+	ReceivedAt time.Time `json:"-"`
 
 	// rawItem holds the raw JSON for deferred item parsing.
 	rawItem json.RawMessage
@@ -68,6 +91,9 @@ func (e *ThreadEvent) UnmarshalJSON(data []byte) error {
 	var aux struct {
 		eventAlias
 		Item json.RawMessage `json:"item,omitempty"`
+		// The turn metadata fields are inlined in the event; the pointer is
+		// only allocated when one of them is present.
+		*TurnInfo
 	}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -76,6 +102,9 @@ func (e *ThreadEvent) UnmarshalJSON(data []byte) error {
 
 	*e = ThreadEvent(aux.eventAlias)
 	e.rawItem = aux.Item
+	if e.Type == EventTurnStarted {
+		e.TurnInfo = aux.TurnInfo
+	}
 
 	if len(aux.Item) > 0 {
 		item, err := unmarshalThreadItem(aux.Item)
@@ -88,6 +117,55 @@ func (e *ThreadEvent) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// checkUnknownFields reports fields in data, the JSON that event was decoded
+// from, that the event and item types do not declare. Payloads of unknown
+// item types are not checked.
+func checkUnknownFields(data []byte, event ThreadEvent) error {
+	type eventAlias ThreadEvent
+	var aux struct {
+		eventAlias
+		Item json.RawMessage `json:"item,omitempty"`
+		*TurnInfo
+	}
+	if err := decodeStrict(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Item) == 0 || event.Item == nil {
+		return nil
+	}
+	if _, unknown := event.Item.(*UnknownItem); unknown {
+		return nil
+	}
+	item := reflect.New(reflect.TypeOf(event.Item).Elem()).Interface()
+	if err := decodeStrict(aux.Item, item); err != nil {
+		return fmt.Errorf("decode thread item: %w", err)
+	}
+	return nil
+}
+
+// decodeStrict decodes data into v, rejecting fields v does not declare.
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// MarshalJSON encodes the event in the JSONL shape emitted by codex exec,
+// including the polymorphic item payload.
+func (e ThreadEvent) MarshalJSON() ([]byte, error) {
+	type eventAlias ThreadEvent
+	return json.Marshal(struct {
+		eventAlias
+		Item ThreadItem `json:"item,omitempty"`
+		*TurnInfo
+	}{
+		eventAlias: eventAlias(e),
+		Item:       e.Item,
+		TurnInfo:   e.TurnInfo,
+	})
+}
+
 // String returns a human-readable representation of the event.
 func (e ThreadEvent) String() string {
 	switch e.Type {
@@ -97,6 +175,9 @@ func (e ThreadEvent) String() string {
 		}
 		return "thread.started"
 	case EventTurnStarted:
+		if e.TurnInfo != nil && e.TurnInfo.Model != "" {
+			return fmt.Sprintf("turn.started model=%s", e.TurnInfo.Model)
+		}
 		return "turn.started"
 	case EventTurnCompleted:
 		if e.Usage != nil {
@@ -0,0 +1,63 @@
+package codex
+
+import "fmt"
+
+// ValidateEventSequence checks the events of a single turn, in the order the
+// CLI emitted them, against the invariants of the exec JSON protocol:
+//
+//   - thread.started is emitted at most once, before any item event;
+//   - item events carry an item, and items of known types have an ID;
+//   - turn.completed and turn.failed are terminal: no event follows them.
+//
+// Violations are reported as errors wrapping ErrInvalidEventSequence. It is
+// exported so that recorded transcripts can be checked in tests; use
+// WithStrictEventValidation to check live turns.
+func ValidateEventSequence(events []ThreadEvent) error {
+	var v eventSequenceValidator
+	for _, event := range events {
+		if err := v.check(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventSequenceValidator checks events incrementally as they are read.
+type eventSequenceValidator struct {
+	index         int
+	threadStarted bool
+	terminal      EventType
+}
+
+func (v *eventSequenceValidator) check(event ThreadEvent) error {
+	index := v.index
+	v.index++
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("%w: event %d (%s): %s", ErrInvalidEventSequence, index, event.Type, fmt.Sprintf(format, args...))
+	}
+
+	if v.terminal != "" {
+		return fail("follows terminal %s event", v.terminal)
+	}
+
+	switch event.Type {
+	case EventThreadStarted:
+		if v.threadStarted {
+			return fail("thread already started")
+		}
+		v.threadStarted = true
+	case EventItemStarted, EventItemUpdated, EventItemCompleted:
+		if !v.threadStarted {
+			return fail("item before thread.started")
+		}
+		if event.Item == nil {
+			return fail("missing item")
+		}
+		if _, unknown := event.Item.(*UnknownItem); !unknown && event.Item.GetID() == "" {
+			return fail("%s item has no ID", event.Item.itemType())
+		}
+	case EventTurnCompleted, EventTurnFailed:
+		v.terminal = event.Type
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package codex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateEventSequence(t *testing.T) {
+	started := ThreadEvent{Type: EventThreadStarted, ThreadID: "thread-1"}
+	turnStarted := ThreadEvent{Type: EventTurnStarted}
+	message := ThreadEvent{Type: EventItemCompleted, Item: &AgentMessageItem{ID: "1", Text: "hi"}}
+	completed := ThreadEvent{Type: EventTurnCompleted, Usage: &Usage{}}
+
+	valid := map[string][]ThreadEvent{
+		"complete turn": {started, turnStarted, message, completed},
+		"failed turn":   {started, turnStarted, {Type: EventError, Message: "boom"}, {Type: EventTurnFailed}},
+		"unknown item":  {started, {Type: EventItemCompleted, Item: &UnknownItem{ItemType: "future"}}},
+		"empty":         nil,
+	}
+	for name, events := range valid {
+		if err := ValidateEventSequence(events); err != nil {
+			t.Errorf("%s: expected a valid sequence, got %v", name, err)
+		}
+	}
+
+	invalid := map[string]struct {
+		events []ThreadEvent
+		reason string
+	}{
+		"item before thread": {[]ThreadEvent{turnStarted, message, completed}, "item before thread.started"},
+		"second thread":      {[]ThreadEvent{started, started}, "thread already started"},
+		"missing item":       {[]ThreadEvent{started, {Type: EventItemStarted}}, "missing item"},
+		"missing item ID":    {[]ThreadEvent{started, {Type: EventItemCompleted, Item: &ReasoningItem{Text: "x"}}}, "reasoning item has no ID"},
+		"after completion":   {[]ThreadEvent{started, completed, message}, "follows terminal turn.completed event"},
+		"after failure":      {[]ThreadEvent{started, {Type: EventTurnFailed}, completed}, "follows terminal turn.failed event"},
+	}
+	for name, tt := range invalid {
+		err := ValidateEventSequence(tt.events)
+		if !errors.Is(err, ErrInvalidEventSequence) || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s: expected ErrInvalidEventSequence mentioning %q, got %v", name, tt.reason, err)
+		}
+	}
+}
+
+func TestRunStrictEventValidation(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"early"}}`,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+
+	if _, err := newFakeThread(t, script).Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("expected lenient Run to succeed, got %v", err)
+	}
+
+	_, err := newFakeThread(t, script, WithStrictEventValidation()).Run(testContext(t), Text("hello"))
+	if !errors.Is(err, ErrInvalidEventSequence) {
+		t.Fatalf("expected ErrInvalidEventSequence, got %v", err)
+	}
+}
@@ -15,7 +15,6 @@ import (
 
 	"github.com/M1n9X/codex-sdk-go"
 	"github.com/M1n9X/codex-sdk-go/examples/internal/exampleutil"
-	"github.com/invopop/jsonschema"
 )
 
 // RepoStatus is the structured shape we want back from Codex.
@@ -45,34 +44,11 @@ func run() error {
 	thread := client.StartThread()
 
 	// Reflect a JSON schema from the Go struct (similar to Zod->JSON Schema).
-	// Configure the reflector to inline the struct instead of using $ref/$defs
-	// because the Codex CLI expects the root schema object directly.
-	reflector := &jsonschema.Reflector{
-		RequiredFromJSONSchemaTags: true,
-		DoNotReference:             true,
-		ExpandedStruct:             true,
-	}
-	rawSchema := reflector.Reflect(&RepoStatus{})
-
-	// The Codex CLI expects a plain JSON object (no $schema/$ref). Normalize
-	// the generated schema to match the TypeScript example shape.
-	var schemaMap map[string]any
-	b, err := json.Marshal(rawSchema)
+	// SchemaFromStruct inlines nested types and strips $schema/$id because the
+	// Codex CLI expects the root schema object directly.
+	schemaMap, err := codex.SchemaFromStruct(&RepoStatus{})
 	if err != nil {
-		return fmt.Errorf("marshal schema: %w", err)
-	}
-	if err := json.Unmarshal(b, &schemaMap); err != nil {
-		return fmt.Errorf("unmarshal schema: %w", err)
-	}
-	delete(schemaMap, "$schema")
-	if _, ok := schemaMap["required"]; !ok {
-		if props, ok := schemaMap["properties"].(map[string]any); ok {
-			req := make([]string, 0, len(props))
-			for k := range props {
-				req = append(req, k)
-			}
-			schemaMap["required"] = req
-		}
+		return fmt.Errorf("build schema: %w", err)
 	}
 
 	fmt.Println("Requesting structured output using a schema derived from a Go struct...")
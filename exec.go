@@ -3,6 +3,9 @@ package codex
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,39 +13,87 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 const (
 	internalOriginatorEnv = "CODEX_INTERNAL_ORIGINATOR_OVERRIDE"
 	goSDKOriginator       = "codex_sdk_go"
+	requestIDEnv          = "CODEX_REQUEST_ID"
+
+	// defaultStderrLimit is the number of trailing stderr bytes kept when
+	// WithStderrLimit is not set.
+	defaultStderrLimit = 64 * 1024
+
+	// headersProviderID is the model provider the SDK defines to attach
+	// WithExtraHeaders headers, since http_headers only applies to providers
+	// declared in config, not to the built-in openai provider.
+	headersProviderID = "codex_sdk"
+
+	// stderrWaitDelay is how long a cancelled CLI has to exit after it is
+	// signalled before it is killed, and how long Wait keeps reading stderr
+	// after the CLI exits, in case a descendant process still holds it.
+	stderrWaitDelay = time.Second
 )
 
 // ExecArgs contains all arguments for running the codex CLI.
 type ExecArgs struct {
-	Input                 string
-	BaseURL               string
-	APIKey                string
-	ThreadID              string
-	Images                []string
-	Model                 string
-	SandboxMode           SandboxMode
-	WorkingDirectory      string
-	SkipGitRepoCheck      bool
-	OutputSchemaFile      string
-	ModelReasoningEffort  ModelReasoningEffort
-	NetworkAccessEnabled  *bool
-	WebSearchEnabled      *bool
-	ApprovalPolicy        ApprovalMode
-	AdditionalDirectories []string
+	Input                  string
+	BaseURL                string
+	APIKey                 string
+	ThreadID               string
+	Images                 []string
+	Model                  string
+	SandboxMode            SandboxMode
+	WorkingDirectory       string
+	SkipGitRepoCheck       bool
+	Ephemeral              bool
+	OutputSchemaFile       string
+	ModelReasoningEffort   ModelReasoningEffort
+	NoReasoningSummary     bool
+	ModelVerbosity         ModelVerbosity
+	ModelContextWindow     int
+	ExtraHeaders           map[string]string
+	NetworkAccessEnabled   *bool
+	SandboxExcludeTmpdir   *bool
+	SandboxExcludeSlashTmp *bool
+	WebSearchEnabled       *bool
+	ApprovalPolicy         ApprovalMode
+	AdditionalDirectories  []string
+	ShellEnvironmentPolicy ShellEnvironmentPolicy
+	WritableRoots          []string
+	PromptCacheKey         string
+	LastMessageFile        string
+	InstructionsFile       string
+	ConfigOverrides        []string
+	ExtraArgs              []string
 }
 
 // Exec manages execution of the codex CLI binary.
 type Exec struct {
 	path string
 	env  map[string]string
+	// envFile holds variables from WithEnvFile, layered under env.
+	envFile map[string]string
+	// homeDir overrides HOME in the CLI's environment.
+	homeDir string
+	// originator overrides the originator taken from the environment.
+	originator string
+	// telemetryDisabled removes the originator from the environment.
+	telemetryDisabled bool
+	// requestIDKey selects the context value exported as CODEX_REQUEST_ID.
+	requestIDKey any
+	// stderrLimit caps the stderr bytes retained for ErrExecFailed.
+	stderrLimit int
+	// processGroup runs the CLI in its own process group.
+	processGroup bool
+	// extraArgs are appended to every invocation before ExecArgs.ExtraArgs.
+	extraArgs []string
 }
 
 // newExec creates a new Exec instance.
@@ -61,6 +112,7 @@ func newExec(pathOverride string, env map[string]string) (*Exec, error) {
 // ExecStream provides access to the running codex process.
 type ExecStream struct {
 	stdout    io.ReadCloser
+	pid       int
 	waitOnce  sync.Once
 	waitErr   error
 	waitFn    func() error
@@ -73,6 +125,11 @@ func (s *ExecStream) Stdout() io.ReadCloser {
 	return s.stdout
 }
 
+// PID returns the process ID of the codex process, or 0 if it was not started.
+func (s *ExecStream) PID() int {
+	return s.pid
+}
+
 // Wait blocks until the process exits and returns any error.
 func (s *ExecStream) Wait() error {
 	s.waitOnce.Do(func() {
@@ -96,6 +153,7 @@ func (s *ExecStream) Close() error {
 // Run starts the codex CLI with the given arguments.
 func (e *Exec) Run(ctx context.Context, args ExecArgs) (*ExecStream, error) {
 	commandArgs := []string{"exec", "--experimental-json"}
+	env := e.buildEnvironment(ctx, args.BaseURL, args.APIKey)
 
 	if args.Model != "" {
 		commandArgs = append(commandArgs, "--model", args.Model)
@@ -119,18 +177,66 @@ func (e *Exec) Run(ctx context.Context, args ExecArgs) (*ExecStream, error) {
 		commandArgs = append(commandArgs, "--skip-git-repo-check")
 	}
 
+	if args.Ephemeral {
+		commandArgs = append(commandArgs, "--ephemeral")
+	}
+
 	if args.OutputSchemaFile != "" {
 		commandArgs = append(commandArgs, "--output-schema", args.OutputSchemaFile)
 	}
 
+	if args.LastMessageFile != "" {
+		commandArgs = append(commandArgs, "--output-last-message", args.LastMessageFile)
+	}
+
+	if args.InstructionsFile != "" {
+		commandArgs = append(commandArgs, "--config", "experimental_instructions_file="+tomlString(args.InstructionsFile))
+	}
+
 	if args.ModelReasoningEffort != "" {
 		commandArgs = append(commandArgs, "--config", fmt.Sprintf(`model_reasoning_effort="%s"`, args.ModelReasoningEffort))
 	}
 
+	if args.NoReasoningSummary {
+		commandArgs = append(commandArgs, "--config", `model_reasoning_summary="none"`)
+	}
+
+	if args.ModelVerbosity != "" {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf(`model_verbosity="%s"`, args.ModelVerbosity))
+	}
+
+	if args.ModelContextWindow > 0 {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf("model_context_window=%d", args.ModelContextWindow))
+	}
+
+	// A model_provider the caller selected is left alone: its own config
+	// decides which headers it sends.
+	if len(args.ExtraHeaders) > 0 && !selectsModelProvider(args.ConfigOverrides, e.extraArgs, args.ExtraArgs) {
+		baseURL := envValue(env, "OPENAI_BASE_URL")
+		if baseURL == "" {
+			return nil, &ErrInvalidInput{
+				Field:  "extra headers",
+				Reason: "need a base URL for the model API; set WithBaseURL or OPENAI_BASE_URL",
+			}
+		}
+		commandArgs = append(commandArgs,
+			"--config", "model_providers."+headersProviderID+"="+headersProvider(baseURL, args),
+			"--config", "model_provider="+tomlString(headersProviderID),
+		)
+	}
+
 	if args.NetworkAccessEnabled != nil {
 		commandArgs = append(commandArgs, "--config", fmt.Sprintf("sandbox_workspace_write.network_access=%t", *args.NetworkAccessEnabled))
 	}
 
+	if args.SandboxExcludeTmpdir != nil {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf("sandbox_workspace_write.exclude_tmpdir_env_var=%t", *args.SandboxExcludeTmpdir))
+	}
+
+	if args.SandboxExcludeSlashTmp != nil {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf("sandbox_workspace_write.exclude_slash_tmp=%t", *args.SandboxExcludeSlashTmp))
+	}
+
 	if args.WebSearchEnabled != nil {
 		commandArgs = append(commandArgs, "--config", fmt.Sprintf("features.web_search_request=%t", *args.WebSearchEnabled))
 	}
@@ -139,18 +245,45 @@ func (e *Exec) Run(ctx context.Context, args ExecArgs) (*ExecStream, error) {
 		commandArgs = append(commandArgs, "--config", fmt.Sprintf(`approval_policy="%s"`, args.ApprovalPolicy))
 	}
 
+	if args.ShellEnvironmentPolicy != "" {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf(`shell_environment_policy.inherit="%s"`, args.ShellEnvironmentPolicy))
+	}
+
+	if len(args.WritableRoots) > 0 {
+		commandArgs = append(commandArgs, "--config", "sandbox_workspace_write.writable_roots="+tomlStringArray(args.WritableRoots))
+	}
+
+	if args.PromptCacheKey != "" {
+		commandArgs = append(commandArgs, "--config", "prompt_cache_key="+tomlString(args.PromptCacheKey))
+	}
+
+	for _, override := range args.ConfigOverrides {
+		commandArgs = append(commandArgs, "--config", override)
+	}
+
 	for _, image := range args.Images {
 		if image != "" {
 			commandArgs = append(commandArgs, "--image", image)
 		}
 	}
 
+	commandArgs = append(commandArgs, e.extraArgs...)
+	commandArgs = append(commandArgs, args.ExtraArgs...)
+
 	if args.ThreadID != "" {
 		commandArgs = append(commandArgs, "resume", args.ThreadID)
 	}
 
 	cmd := exec.CommandContext(ctx, e.path, commandArgs...)
-	cmd.Env = e.buildEnvironment(args.BaseURL, args.APIKey)
+	cmd.Env = env
+	if e.processGroup {
+		setProcessGroup(cmd)
+	}
+	// Cancellation gives the CLI a chance to clean up before WaitDelay
+	// escalates to a kill.
+	cmd.Cancel = func() error {
+		return terminate(cmd, e.processGroup)
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -162,52 +295,61 @@ func (e *Exec) Run(ctx context.Context, args ExecArgs) (*ExecStream, error) {
 		return nil, fmt.Errorf("open stdout pipe: %w", err)
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("open stderr pipe: %w", err)
+	// Letting exec copy stderr makes Wait return only once all of it has
+	// been read. WaitDelay bounds that wait when a descendant process keeps
+	// the pipe open after the CLI exits or is killed.
+	stderrLimit := e.stderrLimit
+	if stderrLimit <= 0 {
+		stderrLimit = defaultStderrLimit
 	}
+	stderrBuf := &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = stderrBuf
+	cmd.WaitDelay = stderrWaitDelay
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("start codex exec: %w", err)
 	}
 
-	stderrBuf := bytes.NewBuffer(nil)
-	stderrDone := make(chan struct{})
-	go func() {
-		defer close(stderrDone)
-		_, _ = io.Copy(stderrBuf, stderr)
-	}()
-
 	writeErrCh := make(chan error, 1)
 	go func() {
+		// Closing stdin on cancellation unblocks a write to a process that
+		// is not reading its input.
+		stopClose := context.AfterFunc(ctx, func() {
+			_ = stdin.Close()
+		})
+		defer stopClose()
 		defer stdin.Close()
-		_, err := io.WriteString(stdin, args.Input)
-		writeErrCh <- err
+		writeErrCh <- writeInput(ctx, stdin, args.Input)
 	}()
 
 	waitFn := func() error {
 		// Wait for process to complete
 		err := cmd.Wait()
+		if errors.Is(err, exec.ErrWaitDelay) {
+			// The CLI exited cleanly but a descendant held stderr open.
+			err = nil
+		}
 
-		// Check if write to stdin failed
+		// Check if write to stdin failed. Wait closes stdin once the process
+		// exits, so a CLI that finishes without reading all of its input
+		// surfaces as a closed pipe rather than a real write failure. The
+		// close also interrupts a write blocked on a full pipe that a
+		// descendant holds open, so this receive cannot outlast Wait.
 		writeErr := <-writeErrCh
-		if writeErr != nil {
+		if writeErr != nil && !errors.Is(writeErr, os.ErrClosed) && !errors.Is(writeErr, syscall.EPIPE) {
 			return fmt.Errorf("write to codex stdin: %w", writeErr)
 		}
 
-		// Ensure stderr is fully drained before checking exit status
-		<-stderrDone
-
 		// Check if process exited with error
 		if err != nil {
 			var exitErr *exec.ExitError
 			if errors.As(err, &exitErr) {
 				stderrText := strings.TrimSpace(stderrBuf.String())
-				return &ErrExecFailed{
+				return classifyExecFailure(stderrText, &ErrExecFailed{
 					ExitCode: exitErr.ExitCode(),
 					Stderr:   stderrText,
 					Err:      err,
-				}
+				})
 			}
 			return fmt.Errorf("codex exec failed: %w", err)
 		}
@@ -215,11 +357,164 @@ func (e *Exec) Run(ctx context.Context, args ExecArgs) (*ExecStream, error) {
 		return nil
 	}
 
-	return &ExecStream{stdout: stdout, waitFn: waitFn}, nil
+	return &ExecStream{stdout: stdout, pid: cmd.Process.Pid, waitFn: waitFn}, nil
+}
+
+// Output runs a one-shot codex command, such as "--version", and returns its
+// standard output. A non-zero exit is reported as *ErrExecFailed.
+func (e *Exec) Output(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, e.path, args...)
+	cmd.Env = e.buildEnvironment(ctx, "", "")
+
+	var stdout bytes.Buffer
+	stderr := &tailBuffer{limit: defaultStderrLimit}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", &ErrExecFailed{
+				ExitCode: exitErr.ExitCode(),
+				Stderr:   strings.TrimSpace(stderr.String()),
+				Err:      err,
+			}
+		}
+		return "", fmt.Errorf("run codex %s: %w", strings.Join(args, " "), err)
+	}
+	return stdout.String(), nil
+}
+
+// tailBuffer is an io.Writer that keeps only the last limit bytes written.
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= b.limit {
+		b.buf = append(b.buf[:0], p[len(p)-b.limit:]...)
+		return n, nil
+	}
+	if overflow := len(b.buf) + len(p) - b.limit; overflow > 0 {
+		b.buf = append(b.buf[:0], b.buf[overflow:]...)
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+// String returns the retained bytes.
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	// JSON string escapes are a subset of TOML basic string escapes.
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// tomlStringArray formats values as a TOML array of strings.
+func tomlStringArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = tomlString(v)
+	}
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
+// tomlStringTable formats values as a TOML inline table of strings with keys
+// in sorted order.
+func tomlStringTable(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = tomlString(k) + "=" + tomlString(values[k])
+	}
+	return "{" + strings.Join(entries, ",") + "}"
+}
+
+// headersProvider formats the inline table defining headersProviderID: an
+// OpenAI Responses API provider at baseURL that sends args.ExtraHeaders. It
+// reads the API key set by WithAPIKey, or otherwise authenticates like the
+// built-in openai provider.
+func headersProvider(baseURL string, args ExecArgs) string {
+	auth := "requires_openai_auth=true"
+	if args.APIKey != "" {
+		auth = "env_key=" + tomlString("CODEX_API_KEY")
+	}
+	return "{name=" + tomlString("OpenAI") +
+		",base_url=" + tomlString(baseURL) +
+		",wire_api=" + tomlString("responses") +
+		"," + auth +
+		",http_headers=" + tomlStringTable(args.ExtraHeaders) + "}"
+}
+
+// selectsModelProvider reports whether overrides, or a --config flag in one
+// of the argument lists, sets model_provider.
+func selectsModelProvider(overrides []string, argLists ...[]string) bool {
+	isModelProvider := func(override string) bool {
+		key, _, ok := strings.Cut(override, "=")
+		return ok && strings.TrimSpace(key) == "model_provider"
+	}
+	if slices.ContainsFunc(overrides, isModelProvider) {
+		return true
+	}
+	for _, list := range argLists {
+		for i, arg := range list {
+			if value, ok := strings.CutPrefix(arg, "--config="); ok && isModelProvider(value) {
+				return true
+			}
+			if (arg == "--config" || arg == "-c") && i+1 < len(list) && isModelProvider(list[i+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envValue returns the value of key in env, a list of KEY=VALUE entries.
+func envValue(env []string, key string) string {
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// stdinChunkSize is the size of each write of the prompt to the CLI's stdin.
+const stdinChunkSize = 32 * 1024
+
+// writeInput writes input to w in chunks, stopping early if ctx is cancelled.
+func writeInput(ctx context.Context, w io.Writer, input string) error {
+	for len(input) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk := input
+		if len(chunk) > stdinChunkSize {
+			chunk = chunk[:stdinChunkSize]
+		}
+		if _, err := io.WriteString(w, chunk); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		input = input[len(chunk):]
+	}
+	return nil
 }
 
 // buildEnvironment constructs the environment for the CLI process.
-func (e *Exec) buildEnvironment(baseURL, apiKey string) []string {
+func (e *Exec) buildEnvironment(ctx context.Context, baseURL, apiKey string) []string {
 	envMap := make(map[string]string)
 
 	if e.env != nil {
@@ -235,19 +530,35 @@ func (e *Exec) buildEnvironment(baseURL, apiKey string) []string {
 			}
 		}
 	}
+	for k, v := range e.envFile {
+		if _, ok := e.env[k]; !ok {
+			envMap[k] = v
+		}
+	}
 
-	// Set SDK originator if not already set
-	if value, ok := envMap[internalOriginatorEnv]; !ok || value == "" {
+	// An explicit originator wins over the environment, which wins over the
+	// SDK default. Disabling telemetry strips it altogether.
+	if e.telemetryDisabled {
+		delete(envMap, internalOriginatorEnv)
+	} else if e.originator != "" {
+		envMap[internalOriginatorEnv] = e.originator
+	} else if value, ok := envMap[internalOriginatorEnv]; !ok || value == "" {
 		envMap[internalOriginatorEnv] = goSDKOriginator
 	}
 
 	// Override with provided values
+	if e.homeDir != "" {
+		envMap["HOME"] = e.homeDir
+	}
 	if baseURL != "" {
 		envMap["OPENAI_BASE_URL"] = baseURL
 	}
 	if apiKey != "" {
 		envMap["CODEX_API_KEY"] = apiKey
 	}
+	if requestID := e.requestID(ctx); requestID != "" {
+		envMap[requestIDEnv] = requestID
+	}
 
 	// Convert to slice
 	env := make([]string, 0, len(envMap))
@@ -258,25 +569,75 @@ func (e *Exec) buildEnvironment(baseURL, apiKey string) []string {
 	return env
 }
 
-// findCodexPath searches for the codex binary in PATH.
+// requestID returns the request ID stored in ctx, if configured.
+func (e *Exec) requestID(ctx context.Context) string {
+	if e.requestIDKey == nil || ctx == nil {
+		return ""
+	}
+	switch v := ctx.Value(e.requestIDKey).(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+// verifyChecksum checks that the binary at e.path has the expected SHA-256 digest.
+func (e *Exec) verifyChecksum(hexDigest string) error {
+	expected, err := hex.DecodeString(strings.TrimSpace(hexDigest))
+	if err != nil || len(expected) != sha256.Size {
+		return &ErrInvalidInput{
+			Field:  "binary checksum",
+			Value:  hexDigest,
+			Reason: "must be a hex-encoded SHA-256 digest",
+		}
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return fmt.Errorf("open codex binary: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hash codex binary: %w", err)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		return fmt.Errorf("%w: %s has sha256 %x, expected %x", ErrChecksumMismatch, e.path, actual, expected)
+	}
+	return nil
+}
+
+// findCodexPath searches for the bundled codex binary, then for codex in PATH.
 func findCodexPath() (string, error) {
-	if bundled := bundledCodexPath(); bundled != "" {
-		return bundled, nil
+	return findCodexPathFor(runtime.GOOS, runtime.GOARCH)
+}
+
+// findCodexPathFor is findCodexPath for the given platform. On platforms
+// without a bundled binary a codex found in PATH is still used; otherwise the
+// error wraps both ErrCodexNotFound and *ErrUnsupportedPlatform.
+func findCodexPathFor(goos, goarch string) (string, error) {
+	targetTriple, platformErr := resolveTargetTriple(goos, goarch)
+	if platformErr == nil {
+		if bundled := bundledCodexPath(targetTriple); bundled != "" {
+			return bundled, nil
+		}
 	}
 
 	codexPath, err := exec.LookPath("codex")
 	if err != nil {
+		if platformErr != nil {
+			return "", fmt.Errorf("%w: %w (install codex in PATH or set WithCodexPath)", ErrCodexNotFound, platformErr)
+		}
 		return "", fmt.Errorf("%w: %v (ensure codex is installed and in PATH)", ErrCodexNotFound, err)
 	}
 	return codexPath, nil
 }
 
-func bundledCodexPath() string {
-	targetTriple, err := resolveTargetTriple(runtime.GOOS, runtime.GOARCH)
-	if err != nil {
-		return ""
-	}
-
+func bundledCodexPath(targetTriple string) string {
 	// Locate the directory containing this source file.
 	_, currentFile, _, ok := runtime.Caller(0)
 	if !ok || currentFile == "" {
@@ -320,5 +681,5 @@ func resolveTargetTriple(goos, goarch string) (string, error) {
 			return "aarch64-pc-windows-msvc", nil
 		}
 	}
-	return "", fmt.Errorf("unsupported platform %s/%s", goos, goarch)
+	return "", &ErrUnsupportedPlatform{GOOS: goos, GOARCH: goarch}
 }
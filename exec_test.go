@@ -3,6 +3,10 @@ package codex
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -257,3 +261,736 @@ func TestExecWithRealCodex(t *testing.T) {
 		t.Logf("Wait returned error (may be expected): %v", err)
 	}
 }
+
+// captureCommandArgs runs Exec with a fake codex script that records its
+// command-line arguments and returns them.
+func captureCommandArgs(t *testing.T, args ExecArgs) []string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("argument capture script is not supported on windows")
+	}
+
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args.txt")
+	scriptPath := filepath.Join(tmpDir, "fake-codex-args.sh")
+	script := "#!/bin/sh\ncat > /dev/null\nfor arg in \"$@\"; do printf '%s\\n' \"$arg\"; done > '" + argsFile + "'\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := exec.Run(ctx, args)
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream.Stdout())
+	for scanner.Scan() {
+	}
+	if err := stream.Wait(); err != nil {
+		t.Fatalf("Wait returned unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("failed to read captured args: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// containsArgPair reports whether args contains flag immediately followed by value.
+func containsArgPair(args []string, flag, value string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag && args[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecShellEnvironmentPolicyArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:                  "test input",
+		ShellEnvironmentPolicy: ShellEnvironmentCore,
+	})
+	if !containsArgPair(args, "--config", `shell_environment_policy.inherit="core"`) {
+		t.Errorf("expected shell environment policy config in args, got %q", args)
+	}
+}
+
+func TestExecWritableRootsArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:         "test input",
+		WritableRoots: []string{"/tmp/build", `C:\out "x"`},
+	})
+	want := `sandbox_workspace_write.writable_roots=["/tmp/build","C:\\out \"x\""]`
+	if !containsArgPair(args, "--config", want) {
+		t.Errorf("expected %q in args, got %q", want, args)
+	}
+}
+
+func TestExecModelContextWindowArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:              "test input",
+		ModelContextWindow: 131072,
+	})
+	if !containsArgPair(args, "--config", "model_context_window=131072") {
+		t.Errorf("expected model_context_window config in args, got %q", args)
+	}
+
+	args = captureCommandArgs(t, ExecArgs{Input: "test input"})
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "model_context_window=") {
+			t.Errorf("expected no context window config when unset, got %q", args)
+		}
+	}
+}
+
+func TestTomlStringArray(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{values: []string{"/a"}, want: `["/a"]`},
+		{values: []string{"/a", "/b"}, want: `["/a","/b"]`},
+		{values: []string{"tab\there"}, want: `["tab\there"]`},
+	}
+	for _, tt := range tests {
+		if got := tomlStringArray(tt.values); got != tt.want {
+			t.Errorf("tomlStringArray(%q) = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestExecModelVerbosityArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:          "test input",
+		ModelVerbosity: VerbosityHigh,
+	})
+	if !containsArgPair(args, "--config", `model_verbosity="high"`) {
+		t.Errorf("expected model verbosity config in args, got %q", args)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestBuildEnvironmentRequestID(t *testing.T) {
+	e := &Exec{env: map[string]string{"FOO": "bar"}, requestIDKey: requestIDKey{}}
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-123")
+
+	env := e.buildEnvironment(ctx, "", "")
+	if !containsString(env, requestIDEnv+"=req-123") {
+		t.Errorf("expected %s in environment, got %q", requestIDEnv, env)
+	}
+
+	env = e.buildEnvironment(context.Background(), "", "")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, requestIDEnv+"=") {
+			t.Errorf("expected no %s without a context value, got %q", requestIDEnv, kv)
+		}
+	}
+
+	unconfigured := &Exec{env: map[string]string{}}
+	for _, kv := range unconfigured.buildEnvironment(ctx, "", "") {
+		if strings.HasPrefix(kv, requestIDEnv+"=") {
+			t.Errorf("expected no %s without a configured key, got %q", requestIDEnv, kv)
+		}
+	}
+}
+
+func TestNewSetsRequestIDKey(t *testing.T) {
+	client, err := New(WithCodexPath("/custom/codex"), WithRequestIDFromContext(requestIDKey{}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if client.exec.requestIDKey != (requestIDKey{}) {
+		t.Errorf("expected request ID key to be propagated to exec, got %v", client.exec.requestIDKey)
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBinaryChecksum(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "codex")
+	content := []byte("#!/bin/sh\nexit 0\n")
+	if err := os.WriteFile(binary, content, 0o755); err != nil {
+		t.Fatalf("failed to create binary: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	if _, err := New(WithCodexPath(binary), WithBinaryChecksum(digest)); err != nil {
+		t.Errorf("expected matching checksum to succeed, got: %v", err)
+	}
+	if _, err := New(WithCodexPath(binary), WithBinaryChecksum(strings.ToUpper(digest))); err != nil {
+		t.Errorf("expected upper-case checksum to succeed, got: %v", err)
+	}
+
+	wrong := strings.Repeat("0", sha256.Size*2)
+	if _, err := New(WithCodexPath(binary), WithBinaryChecksum(wrong)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got: %v", err)
+	}
+
+	var invalidInput *ErrInvalidInput
+	if _, err := New(WithCodexPath(binary), WithBinaryChecksum("not-hex")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for malformed digest, got: %v", err)
+	}
+}
+
+func TestExecPromptCacheKeyArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:          "test input",
+		PromptCacheKey: "session-42",
+	})
+	if !containsArgPair(args, "--config", `prompt_cache_key="session-42"`) {
+		t.Errorf("expected prompt cache key config in args, got %q", args)
+	}
+}
+
+func TestExecLastMessageFileArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{
+		Input:           "test input",
+		LastMessageFile: "/tmp/last.txt",
+	})
+	if !containsArgPair(args, "--output-last-message", "/tmp/last.txt") {
+		t.Errorf("expected --output-last-message in args, got %q", args)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{limit: 8}
+	for _, chunk := range []string{"abc", "defg", "hijkl"} {
+		n, err := b.Write([]byte(chunk))
+		if err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if got := b.String(); got != "efghijkl" {
+		t.Errorf("expected tail %q, got %q", "efghijkl", got)
+	}
+
+	b.Write([]byte("0123456789"))
+	if got := b.String(); got != "23456789" {
+		t.Errorf("expected tail %q after oversized write, got %q", "23456789", got)
+	}
+}
+
+func TestExecStderrLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stderr script is not supported on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex-stderr.sh")
+	script := `#!/bin/sh
+cat > /dev/null
+i=0
+while [ $i -lt 2000 ]; do
+  echo "noise line $i" >&2
+  i=$((i+1))
+done
+echo 'final fatal error' >&2
+exit 3
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+	exec.stderrLimit = 64
+
+	stream, err := exec.Run(context.Background(), ExecArgs{Input: "test input"})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+	scanner := bufio.NewScanner(stream.Stdout())
+	for scanner.Scan() {
+	}
+
+	var execErr *ErrExecFailed
+	if err := stream.Wait(); !errors.As(err, &execErr) {
+		t.Fatalf("expected ErrExecFailed, got %v", err)
+	}
+	if len(execErr.Stderr) > 64 {
+		t.Errorf("expected at most 64 bytes of stderr, got %d", len(execErr.Stderr))
+	}
+	if !strings.HasSuffix(execErr.Stderr, "final fatal error") {
+		t.Errorf("expected stderr tail to be retained, got %q", execErr.Stderr)
+	}
+	if strings.Contains(execErr.Stderr, "noise line 0\n") {
+		t.Errorf("expected early stderr to be discarded, got %q", execErr.Stderr)
+	}
+}
+
+func TestWriteInputChunksAndCancellation(t *testing.T) {
+	var buf strings.Builder
+	input := strings.Repeat("a", stdinChunkSize*2+10)
+	if err := writeInput(context.Background(), &buf, input); err != nil {
+		t.Fatalf("writeInput failed: %v", err)
+	}
+	if buf.String() != input {
+		t.Errorf("expected %d bytes written, got %d", len(input), buf.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	if err := writeInput(ctx, &buf, input); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing written after cancellation, got %d bytes", buf.Len())
+	}
+}
+
+func TestExecStderrHeldByDescendant(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+	// The background sleep inherits stderr and outlives the CLI.
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex.sh")
+	script := `#!/bin/sh
+cat > /dev/null
+sleep 10 < /dev/null > /dev/null &
+echo 'still running' >&2
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+exit 0
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+
+	stream, err := exec.Run(context.Background(), ExecArgs{Input: "test input"})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stream.Stdout())
+		for scanner.Scan() {
+		}
+		done <- stream.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean exit, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait blocked on stderr held by a descendant")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected Wait to return promptly, took %s", elapsed)
+	}
+}
+
+func TestExecUnreadInputIsNotAWriteError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex.sh")
+	script := `#!/bin/sh
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+exit 0
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+
+	stream, err := exec.Run(testContext(t), ExecArgs{Input: strings.Repeat("x", 1024*1024)})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream.Stdout())
+	for scanner.Scan() {
+	}
+	if err := stream.Wait(); err != nil {
+		t.Errorf("expected a CLI exiting without reading its input to succeed, got %v", err)
+	}
+}
+
+func TestExecLargeInputCancellation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep script is not supported on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex-stuck.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := exec.Run(ctx, ExecArgs{Input: strings.Repeat("x", 16*1024*1024)})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stream.Stdout())
+		for scanner.Scan() {
+		}
+		done <- stream.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("exec did not exit after cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected prompt exit after cancellation, took %s", elapsed)
+	}
+}
+
+func TestExecLargeInputEarlyExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+
+	tests := map[string]string{
+		// The CLI exits without reading its input.
+		"exits": `echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+exit 0
+`,
+		// A descendant inherits stdin and outlives the CLI without reading,
+		// so the pipe stays open and the writer blocks on a full buffer.
+		"descendant holds stdin": `sleep 3 <&0 >/dev/null 2>&1 &
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+exit 0
+`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			scriptPath := filepath.Join(t.TempDir(), "fake-codex.sh")
+			if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+				t.Fatalf("failed to create fake codex script: %v", err)
+			}
+			exec, err := newExec(scriptPath, nil)
+			if err != nil {
+				t.Fatalf("failed to create exec: %v", err)
+			}
+
+			stream, err := exec.Run(context.Background(), ExecArgs{Input: strings.Repeat("x", 16*1024*1024)})
+			if err != nil {
+				t.Fatalf("failed to start exec: %v", err)
+			}
+			defer stream.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				_, _ = io.Copy(io.Discard, stream.Stdout())
+				done <- stream.Wait()
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("expected an unread prompt not to fail the run, got %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Wait blocked on the stdin writer after the process exited")
+			}
+		})
+	}
+}
+
+func TestExecSandboxTmpArgs(t *testing.T) {
+	args := captureCommandArgs(t, ExecArgs{Input: "test input"})
+	for _, arg := range args {
+		if strings.Contains(arg, "exclude_") {
+			t.Errorf("expected no tmp exclusion config when unset, got %q", args)
+		}
+	}
+
+	excludeTmpdir, excludeSlashTmp := true, false
+	args = captureCommandArgs(t, ExecArgs{
+		Input:                  "test input",
+		SandboxExcludeTmpdir:   &excludeTmpdir,
+		SandboxExcludeSlashTmp: &excludeSlashTmp,
+	})
+	if !containsArgPair(args, "--config", "sandbox_workspace_write.exclude_tmpdir_env_var=true") {
+		t.Errorf("expected exclude_tmpdir_env_var config in args, got %q", args)
+	}
+	if !containsArgPair(args, "--config", "sandbox_workspace_write.exclude_slash_tmp=false") {
+		t.Errorf("expected exclude_slash_tmp config in args, got %q", args)
+	}
+}
+
+func TestBuildEnvironmentOriginator(t *testing.T) {
+	want := func(t *testing.T, env []string, originator string) {
+		t.Helper()
+		if !containsString(env, internalOriginatorEnv+"="+originator) {
+			t.Errorf("expected originator %q, got %q", originator, env)
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		e := &Exec{env: map[string]string{"FOO": "bar"}}
+		want(t, e.buildEnvironment(context.Background(), "", ""), goSDKOriginator)
+	})
+	t.Run("env map", func(t *testing.T) {
+		e := &Exec{env: map[string]string{internalOriginatorEnv: "from_env"}}
+		want(t, e.buildEnvironment(context.Background(), "", ""), "from_env")
+	})
+	t.Run("inherited env", func(t *testing.T) {
+		t.Setenv(internalOriginatorEnv, "inherited")
+		e := &Exec{}
+		want(t, e.buildEnvironment(context.Background(), "", ""), "inherited")
+	})
+	t.Run("option", func(t *testing.T) {
+		t.Setenv(internalOriginatorEnv, "inherited")
+		client, err := New(WithCodexPath("/custom/codex"), WithOriginator("my_app"),
+			WithEnv(map[string]string{internalOriginatorEnv: "from_env"}))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		want(t, client.exec.buildEnvironment(context.Background(), "", ""), "my_app")
+
+		client, err = New(WithCodexPath("/custom/codex"), WithOriginator("my_app"))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		want(t, client.exec.buildEnvironment(context.Background(), "", ""), "my_app")
+	})
+}
+
+func TestBuildEnvironmentTelemetryDisabled(t *testing.T) {
+	absent := func(t *testing.T, env []string) {
+		t.Helper()
+		for _, kv := range env {
+			if strings.HasPrefix(kv, internalOriginatorEnv+"=") {
+				t.Errorf("expected no %s, got %q", internalOriginatorEnv, kv)
+			}
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		client, err := New(WithCodexPath("/custom/codex"), WithTelemetryDisabled())
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		absent(t, client.exec.buildEnvironment(context.Background(), "", ""))
+	})
+	t.Run("inherited env", func(t *testing.T) {
+		t.Setenv(internalOriginatorEnv, "inherited")
+		client, err := New(WithCodexPath("/custom/codex"), WithTelemetryDisabled())
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		absent(t, client.exec.buildEnvironment(context.Background(), "", ""))
+	})
+	t.Run("env map and originator", func(t *testing.T) {
+		client, err := New(WithCodexPath("/custom/codex"), WithTelemetryDisabled(), WithOriginator("my_app"),
+			WithEnv(map[string]string{internalOriginatorEnv: "from_env", "FOO": "bar"}))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		env := client.exec.buildEnvironment(context.Background(), "", "")
+		absent(t, env)
+		if !containsString(env, "FOO=bar") {
+			t.Errorf("expected other variables to be kept, got %q", env)
+		}
+	})
+}
+
+func TestBuildEnvironmentHomeDir(t *testing.T) {
+	home := t.TempDir()
+	client, err := New(WithCodexPath("/custom/codex"), WithHomeDir(home), WithEnv(map[string]string{"HOME": "/nonexistent"}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// The CLI resolves its credentials and config under $HOME/.codex.
+	env := client.exec.buildEnvironment(context.Background(), "", "")
+	if !containsString(env, "HOME="+home) {
+		t.Errorf("expected HOME=%s in environment, got %q", home, env)
+	}
+}
+
+func TestExecExtraHeadersArgs(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "https://api.openai.com/v1")
+	args := captureCommandArgs(t, ExecArgs{
+		Input:        "test input",
+		ExtraHeaders: map[string]string{"X-Tenant": "acme", "Authorization-Gateway": `token "x"`},
+	})
+	want := `model_providers.codex_sdk={name="OpenAI",base_url="https://api.openai.com/v1",wire_api="responses",requires_openai_auth=true,http_headers={"Authorization-Gateway"="token \"x\"","X-Tenant"="acme"}}`
+	if !containsArgPair(args, "--config", want) {
+		t.Errorf("expected %q in args, got %q", want, args)
+	}
+	if !containsArgPair(args, "--config", `model_provider="codex_sdk"`) {
+		t.Errorf("expected the headers provider to be selected, got %q", args)
+	}
+
+	args = captureCommandArgs(t, ExecArgs{
+		Input:        "test input",
+		BaseURL:      "https://gateway.example.com/v1",
+		APIKey:       "sk-test",
+		ExtraHeaders: map[string]string{"X-Tenant": "acme"},
+	})
+	want = `model_providers.codex_sdk={name="OpenAI",base_url="https://gateway.example.com/v1",wire_api="responses",env_key="CODEX_API_KEY",http_headers={"X-Tenant"="acme"}}`
+	if !containsArgPair(args, "--config", want) {
+		t.Errorf("expected %q in args, got %q", want, args)
+	}
+
+	args = captureCommandArgs(t, ExecArgs{Input: "test input"})
+	for _, arg := range args {
+		if strings.Contains(arg, "http_headers") || strings.HasPrefix(arg, "model_provider=") {
+			t.Errorf("expected no header config when unset, got %q", args)
+		}
+	}
+
+	for _, userArgs := range []ExecArgs{
+		{ConfigOverrides: []string{`model_provider="azure"`}},
+		{ExtraArgs: []string{"-c", `model_provider="azure"`}},
+		{ExtraArgs: []string{`--config=model_provider="azure"`}},
+	} {
+		userArgs.Input = "test input"
+		userArgs.ExtraHeaders = map[string]string{"X-Tenant": "acme"}
+		args = captureCommandArgs(t, userArgs)
+		if containsArgPair(args, "--config", `model_provider="codex_sdk"`) {
+			t.Errorf("expected the user's model_provider to be kept, got %q", args)
+		}
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "model_providers.codex_sdk=") {
+				t.Errorf("expected no headers provider, got %q", args)
+			}
+		}
+	}
+}
+
+func TestRunExtraHeaders(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "https://api.openai.com/v1")
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	headers := map[string]string{"X-Tenant": "acme"}
+	client, err := New(WithCodexPath(script), WithExtraHeaders(headers))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	headers["X-Tenant"] = "changed"
+
+	if _, err := client.StartThread().Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--config", `model_providers.codex_sdk={name="OpenAI",base_url="https://api.openai.com/v1",wire_api="responses",requires_openai_auth=true,http_headers={"X-Tenant"="acme"}}`) {
+		t.Errorf("expected header config in args, got %q", args)
+	}
+}
+
+func TestRunExtraHeadersBaseURLFromEnv(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(
+		WithCodexPath(script),
+		WithEnv(map[string]string{"OPENAI_BASE_URL": "https://gateway.example.com/v1"}),
+		WithExtraHeaders(map[string]string{"X-Tenant": "acme"}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.StartThread().Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--config", `model_providers.codex_sdk={name="OpenAI",base_url="https://gateway.example.com/v1",wire_api="responses",requires_openai_auth=true,http_headers={"X-Tenant"="acme"}}`) {
+		t.Errorf("expected the environment's base URL in the header config, got %q", args)
+	}
+}
+
+func TestRunExtraHeadersWithoutBaseURL(t *testing.T) {
+	script, _ := createFakeCodexArgsRecorder(t)
+	client, err := New(
+		WithCodexPath(script),
+		WithEnv(map[string]string{"PATH": os.Getenv("PATH")}),
+		WithExtraHeaders(map[string]string{"X-Tenant": "acme"}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = client.StartThread().Run(testContext(t), Text("hello"))
+	var invalid *ErrInvalidInput
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected ErrInvalidInput without a base URL, got %v", err)
+	}
+}
+
+func TestFindCodexPathUnsupportedPlatform(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := findCodexPathFor("plan9", "amd64")
+	var platformErr *ErrUnsupportedPlatform
+	if !errors.As(err, &platformErr) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if platformErr.GOOS != "plan9" || platformErr.GOARCH != "amd64" {
+		t.Errorf("unexpected platform %s/%s", platformErr.GOOS, platformErr.GOARCH)
+	}
+	if !errors.Is(err, ErrCodexNotFound) {
+		t.Errorf("expected the error to wrap ErrCodexNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "plan9/amd64") {
+		t.Errorf("expected the platform in the message, got %q", err)
+	}
+}
+
+func TestFindCodexPathUnsupportedPlatformUsesPATH(t *testing.T) {
+	script := createFakeCodexShellScript(t, "exit 0\n")
+	dir := filepath.Dir(script)
+	if err := os.Rename(script, filepath.Join(dir, "codex")); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	path, err := findCodexPathFor("plan9", "amd64")
+	if err != nil {
+		t.Fatalf("expected codex in PATH to be used, got %v", err)
+	}
+	if path != filepath.Join(dir, "codex") {
+		t.Errorf("expected %s, got %s", filepath.Join(dir, "codex"), path)
+	}
+}
@@ -0,0 +1,51 @@
+package codex
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// unsupportedFlagPatterns match the errors argument parsers print for flags
+// they do not recognize, capturing the flag.
+var unsupportedFlagPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`unexpected argument '([^']+)'`),
+	regexp.MustCompile(`[Ff]ound argument '([^']+)' which wasn't expected`),
+	regexp.MustCompile(`(?i)(?:unrecognized|unknown) (?:argument|option|flag)s?:? '?(--?[\w-]+)`),
+}
+
+// parseUnsupportedFlag returns the flag named by an unrecognized-argument
+// error in stderr, or "" if there is none.
+func parseUnsupportedFlag(stderr string) string {
+	for _, pattern := range unsupportedFlagPatterns {
+		if m := pattern.FindStringSubmatch(stderr); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// sessionNotFoundPattern recognizes the errors the CLI prints when the
+// session to resume is missing or cannot be loaded.
+var sessionNotFoundPattern = regexp.MustCompile(`(?i)no (?:saved )?(?:session|conversation|rollout)s? (?:file )?found|(?:session|conversation|rollout)(?: file)? not found|failed to (?:load|read|parse|resume) (?:session|conversation|rollout)`)
+
+// contextLengthPattern recognizes errors reporting that the input exceeds
+// the model's context window.
+var contextLengthPattern = regexp.MustCompile(`(?i)context_length_exceeded|maximum context length|context (?:length|window)\b[^\n]*exceed|exceeds? (?:the )?(?:model'?s? )?context (?:length|window)|prompt is too long`)
+
+// classifyExecFailure wraps an exec failure in a more specific error when
+// stderr identifies the cause, and returns err unchanged otherwise.
+func classifyExecFailure(stderr string, err error) error {
+	if flag := parseUnsupportedFlag(stderr); flag != "" {
+		return &ErrUnsupportedFlag{Flag: flag, Err: err}
+	}
+	if contextLengthPattern.MatchString(stderr) {
+		return fmt.Errorf("%w: %w", ErrContextLengthExceeded, err)
+	}
+	if sessionNotFoundPattern.MatchString(stderr) {
+		return fmt.Errorf("%w: %w", ErrSessionNotFound, err)
+	}
+	if modelUnavailablePattern.MatchString(stderr) {
+		return fmt.Errorf("%w: %w", ErrModelUnavailable, err)
+	}
+	return rateLimitError(stderr, err)
+}
@@ -0,0 +1,122 @@
+package codex
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseUnsupportedFlag(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   string
+	}{
+		{"error: unexpected argument '--ephemeral' found\n\nUsage: codex exec [OPTIONS] [PROMPT]", "--ephemeral"},
+		{"error: Found argument '--output-schema' which wasn't expected, or isn't valid in this context", "--output-schema"},
+		{"error: unrecognized option '--cd'", "--cd"},
+		{"Unknown flag: --image", "--image"},
+		{"error: stream disconnected before completion", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseUnsupportedFlag(tt.stderr); got != tt.want {
+			t.Errorf("parseUnsupportedFlag(%q) = %q, want %q", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func TestExecUnsupportedFlag(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stderr script is not supported on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex-old.sh")
+	script := `#!/bin/sh
+cat > /dev/null
+echo "error: unexpected argument '--ephemeral' found" >&2
+echo >&2
+echo "Usage: codex exec [OPTIONS] [PROMPT]" >&2
+exit 2
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+	stream, err := exec.Run(context.Background(), ExecArgs{Input: "test input", Ephemeral: true})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+	scanner := bufio.NewScanner(stream.Stdout())
+	for scanner.Scan() {
+	}
+
+	err = stream.Wait()
+	var unsupported *ErrUnsupportedFlag
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected ErrUnsupportedFlag, got %v", err)
+	}
+	if unsupported.Flag != "--ephemeral" {
+		t.Errorf("expected flag %q, got %q", "--ephemeral", unsupported.Flag)
+	}
+	if !strings.Contains(err.Error(), "upgrade") {
+		t.Errorf("expected error to suggest upgrading, got %q", err)
+	}
+	var execErr *ErrExecFailed
+	if !errors.As(err, &execErr) || execErr.ExitCode != 2 {
+		t.Errorf("expected wrapped ErrExecFailed with exit code 2, got %v", err)
+	}
+}
+
+func TestClassifyExecFailureSessionNotFound(t *testing.T) {
+	base := &ErrExecFailed{ExitCode: 1}
+	for _, stderr := range []string{
+		"Error: no session found with ID 0199a213",
+		"Error: No saved session found",
+		"rollout file not found: /home/u/.codex/sessions/x.jsonl",
+		"failed to load conversation: invalid JSON",
+	} {
+		err := classifyExecFailure(stderr, base)
+		if !errors.Is(err, ErrSessionNotFound) || !errors.Is(err, base) {
+			t.Errorf("expected ErrSessionNotFound wrapping the exec failure for %q, got %v", stderr, err)
+		}
+	}
+
+	if err := classifyExecFailure("model not found", base); errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected no session error for an unrelated failure, got %v", err)
+	}
+}
+
+func TestClassifyExecFailureModelUnavailable(t *testing.T) {
+	base := &ErrExecFailed{ExitCode: 1}
+	for _, stderr := range []string{
+		"Error: unsupported model gpt-4.1",
+		"unexpected status 404 Not Found: The model `gpt-9` does not exist",
+		`{"error":{"code":"model_not_found"}}`,
+		"Error: model 'gpt-9' not found",
+	} {
+		err := classifyExecFailure(stderr, base)
+		if !errors.Is(err, ErrModelUnavailable) || !errors.Is(err, base) {
+			t.Errorf("expected ErrModelUnavailable wrapping the exec failure for %q, got %v", stderr, err)
+		}
+	}
+
+	for _, stderr := range []string{
+		"permission denied",
+		"Error: model is overloaded and unavailable, try again later",
+		"model produced a patch but file not found: a.go",
+	} {
+		if err := classifyExecFailure(stderr, base); errors.Is(err, ErrModelUnavailable) {
+			t.Errorf("expected no model error for %q, got %v", stderr, err)
+		}
+	}
+}
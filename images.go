@@ -0,0 +1,169 @@
+package codex
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	// Register decoders for the formats accepted as local images.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// defaultImageQuality is the JPEG quality used by WithImageAutoCompress when
+// quality is zero.
+const defaultImageQuality = 85
+
+// maxImagePixels caps the width×height of images WithImageAutoCompress
+// decodes. Larger images, such as decompression bombs whose header claims
+// huge dimensions, are passed through unresized instead of being decoded
+// into memory.
+const maxImagePixels = 50_000_000
+
+// compressedImages holds the images of a turn after WithImageAutoCompress,
+// with downscaled copies written to a temporary directory.
+type compressedImages struct {
+	dir string
+	// paths are the image paths to pass to the CLI, in input order.
+	paths []string
+	// written are the temporary files created for downscaled images.
+	written []string
+}
+
+// Written returns the temporary files created for downscaled images.
+func (c *compressedImages) Written() []string {
+	if c == nil {
+		return nil
+	}
+	return c.written
+}
+
+// Cleanup removes the downscaled copies.
+func (c *compressedImages) Cleanup() error {
+	if c == nil || c.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(c.dir)
+}
+
+// compressImages downscales images larger than maxDim on either side to fit
+// within maxDim and re-encodes them as JPEG at quality. Files that are not
+// decodable images, already fit or exceed maxImagePixels are passed through
+// unchanged.
+func compressImages(paths []string, maxDim, quality int) (*compressedImages, error) {
+	if quality == 0 {
+		quality = defaultImageQuality
+	}
+
+	c := &compressedImages{paths: make([]string, len(paths))}
+	for i, path := range paths {
+		c.paths[i] = path
+
+		img, err := decodeLargeImage(path, maxDim)
+		if err != nil {
+			_ = c.Cleanup()
+			return nil, err
+		}
+		if img == nil {
+			continue
+		}
+
+		if c.dir == "" {
+			if c.dir, err = os.MkdirTemp("", "codex-images-"); err != nil {
+				return nil, fmt.Errorf("create image directory: %w", err)
+			}
+		}
+		out := filepath.Join(c.dir, fmt.Sprintf("image-%d.jpg", i))
+		if err := writeJPEG(out, downscale(img, maxDim), quality); err != nil {
+			_ = c.Cleanup()
+			return nil, err
+		}
+		c.paths[i] = out
+		c.written = append(c.written, out)
+	}
+	return c, nil
+}
+
+// decodeLargeImage decodes the image at path if it is larger than maxDim on
+// either side. It returns nil for smaller images, for images over
+// maxImagePixels and for files in formats the standard library cannot decode.
+func decodeLargeImage(path string, maxDim int) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil || (config.Width <= maxDim && config.Height <= maxDim) {
+		return nil, nil
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode image %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// downscale resizes src to fit within maxDim on both sides, keeping its
+// aspect ratio. Each output pixel averages the source pixels it covers, and
+// transparent areas are flattened onto white since JPEG has no alpha.
+func downscale(src image.Image, maxDim int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := maxDim, maxDim
+	if w >= h {
+		dh = max(1, (h*maxDim+w/2)/w)
+	} else {
+		dw = max(1, (w*maxDim+h/2)/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					// Colors are alpha-premultiplied, so adding the
+					// missing coverage composites them over white.
+					r += uint64(pr + 0xffff - pa)
+					g += uint64(pg + 0xffff - pa)
+					bl += uint64(pb + 0xffff - pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+func writeJPEG(path string, img image.Image, quality int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create compressed image: %w", err)
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		f.Close()
+		return fmt.Errorf("encode compressed image: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,137 @@
+package codex
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePNGFixture writes a w×h PNG with a horizontal gradient.
+func writePNGFixture(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / w), G: 128, B: 64, A: 0xff})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "fixture.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create fixture: %v", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("failed to encode fixture: %v", err)
+	}
+	return path
+}
+
+func decodeImageConfig(t *testing.T, path string) (image.Config, string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+	defer f.Close()
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+	return config, format
+}
+
+func TestCompressImages(t *testing.T) {
+	large := writePNGFixture(t, 1200, 800)
+	tall := writePNGFixture(t, 300, 900)
+	small := writePNGFixture(t, 100, 50)
+	notImage := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notImage, []byte("not an image"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	compressed, err := compressImages([]string{large, small, notImage, tall}, 300, 70)
+	if err != nil {
+		t.Fatalf("compressImages failed: %v", err)
+	}
+
+	if compressed.paths[1] != small || compressed.paths[2] != notImage {
+		t.Errorf("expected small and non-image files to pass through, got %q", compressed.paths)
+	}
+	for i, want := range map[int]image.Point{0: {300, 200}, 3: {100, 300}} {
+		config, format := decodeImageConfig(t, compressed.paths[i])
+		if format != "jpeg" || config.Width != want.X || config.Height != want.Y {
+			t.Errorf("image %d: expected %dx%d jpeg, got %dx%d %s", i, want.X, want.Y, config.Width, config.Height, format)
+		}
+	}
+	if len(compressed.Written()) != 2 {
+		t.Errorf("expected two temporary images, got %q", compressed.Written())
+	}
+
+	if err := compressed.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	for _, path := range compressed.Written() {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s to be removed, got %v", path, err)
+		}
+	}
+}
+
+func TestCompressImagesSkipsOversizedImages(t *testing.T) {
+	// Rewrite the IHDR dimensions of a small PNG to claim 100000×100000
+	// pixels, as a decompression bomb would.
+	path := writePNGFixture(t, 10, 10)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	ihdr := data[12:29] // chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:8], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 100000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(ihdr))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if config, _ := decodeImageConfig(t, path); config.Width != 100000 {
+		t.Fatalf("expected patched width, got %d", config.Width)
+	}
+
+	compressed, err := compressImages([]string{path}, 300, 70)
+	if err != nil {
+		t.Fatalf("compressImages failed: %v", err)
+	}
+	defer compressed.Cleanup()
+	if compressed.paths[0] != path || len(compressed.Written()) != 0 {
+		t.Errorf("expected the oversized image to pass through, got %q", compressed.paths)
+	}
+}
+
+func TestRunImageAutoCompress(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script)
+	large := writePNGFixture(t, 1000, 1000)
+
+	var temp []string
+	_, err := thread.Run(testContext(t), Compose(TextPart("describe"), ImagePart(large)),
+		WithImageAutoCompress(256, 0),
+		WithTurnTempFilesHook(func(paths []string) { temp = paths }))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	args := readRecordedArgs(t, argsFile)
+	if len(temp) != 1 || !containsArgPair(args, "--image", temp[0]) {
+		t.Fatalf("expected the downscaled image %q to be passed, got args %q", temp, args)
+	}
+	if _, err := os.Stat(temp[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the downscaled image to be removed after the turn, got %v", err)
+	}
+}
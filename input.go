@@ -1,9 +1,13 @@
 package codex
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"strings"
+	"text/template"
 )
 
 // Input represents the user-provided content for a single agent turn.
@@ -25,6 +29,68 @@ func Compose(parts ...UserInput) Input {
 	return Input{parts: cp}
 }
 
+// DefaultMaxInputFileSize is the largest prompt file InputFromFile reads.
+const DefaultMaxInputFileSize = 1 << 20
+
+// InputFromFile reads a prompt from the file at path and returns it as a
+// text Input. Files larger than DefaultMaxInputFileSize are rejected; use
+// InputFromFileLimit to choose another limit.
+func InputFromFile(path string) (Input, error) {
+	return InputFromFileLimit(path, DefaultMaxInputFileSize)
+}
+
+// InputFromFileLimit is like InputFromFile but rejects files larger than
+// maxBytes. It returns *ErrInvalidInput when the file is missing, unreadable,
+// or too large.
+func InputFromFileLimit(path string, maxBytes int64) (Input, error) {
+	if err := validateReadableFile("input file", path); err != nil {
+		return Input{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Input{}, err
+	}
+	defer f.Close()
+
+	// Read one byte past the limit so that a file growing after a size check
+	// cannot slip through.
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return Input{}, fmt.Errorf("read input file: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return Input{}, &ErrInvalidInput{
+			Field:  "input file",
+			Value:  path,
+			Reason: fmt.Sprintf("file exceeds the %d byte limit", maxBytes),
+		}
+	}
+	return Text(string(data)), nil
+}
+
+// PromptTemplate renders tmpl as a text/template with vars and returns the
+// result as a text Input. Referencing a variable missing from vars is an
+// error, as is a template that fails to parse or execute.
+//
+// Example:
+//
+//	input, err := codex.PromptTemplate("Fix the failing test in {{.File}}", map[string]any{
+//		"File": "parser_test.go",
+//	})
+func PromptTemplate(tmpl string, vars map[string]any) (Input, error) {
+	t, err := template.New("prompt").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return Input{}, fmt.Errorf("parse prompt template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return Input{}, fmt.Errorf("render prompt template: %w", err)
+	}
+	return Text(b.String()), nil
+}
+
 // InputType enumerates the supported user input kinds.
 type InputType string
 
@@ -78,6 +144,13 @@ func normalizeInput(input Input) (prompt string, images []string, err error) {
 					Reason: fmt.Sprintf("input part %d: local image path must be set", idx),
 				}
 			}
+			if err := validateReadableFile("image path", part.Path); err != nil {
+				var invalid *ErrInvalidInput
+				if errors.As(err, &invalid) {
+					invalid.Reason = fmt.Sprintf("input part %d: %s", idx, invalid.Reason)
+				}
+				return "", nil, err
+			}
 			images = append(images, part.Path)
 		case "":
 			return "", nil, &ErrInvalidInput{
@@ -98,6 +171,17 @@ func normalizeInput(input Input) (prompt string, images []string, err error) {
 	return prompt, images, nil
 }
 
+// contextSeparator divides context blocks from the user prompt.
+const contextSeparator = "\n\n---\n\n"
+
+// prependContext places context blocks ahead of the prompt.
+func prependContext(contextBlocks []string, prompt string) string {
+	if len(contextBlocks) == 0 {
+		return prompt
+	}
+	return strings.Join(contextBlocks, "\n\n") + contextSeparator + prompt
+}
+
 // validateOutputSchema ensures the schema marshals to a JSON object.
 func validateOutputSchema(schema any) error {
 	if schema == nil {
@@ -0,0 +1,85 @@
+package codex
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// tempDirs tracks temporary directories, and other resources such as git
+// worktrees, owned by a client so that Close can remove them.
+type tempDirs struct {
+	mu       sync.Mutex
+	cleanups []func() error
+}
+
+// add registers a directory for removal. A nil registry ignores the call.
+func (d *tempDirs) add(path string) {
+	d.addFunc(func() error {
+		return os.RemoveAll(path)
+	})
+}
+
+// addFunc registers a cleanup function. A nil registry ignores the call.
+func (d *tempDirs) addFunc(fn func() error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.cleanups = append(d.cleanups, fn)
+	d.mu.Unlock()
+}
+
+// removeAll runs every registered cleanup and returns the first error.
+func (d *tempDirs) removeAll() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	cleanups := d.cleanups
+	d.cleanups = nil
+	d.mu.Unlock()
+
+	var firstErr error
+	for _, cleanup := range cleanups {
+		if err := cleanup(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// instructionsFile returns the path of the file holding the thread's custom
+// instructions. Inline instructions are written to a file on first use; the
+// file is reused by every turn of the thread and removed when the client is
+// closed.
+func (t *Thread) instructionsFile() (string, error) {
+	if t.threadOptions.InstructionsFile != "" {
+		// The CLI resolves relative paths against --cd, not the SDK's
+		// working directory.
+		return filepath.Abs(t.threadOptions.InstructionsFile)
+	}
+	if t.threadOptions.Instructions == "" {
+		return "", nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.instructionsPath != "" {
+		return t.instructionsPath, nil
+	}
+
+	dir, err := os.MkdirTemp("", "codex-instructions-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "instructions.md")
+	if err := os.WriteFile(path, []byte(t.threadOptions.Instructions), 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	t.tempDirs.add(dir)
+	t.instructionsPath = path
+	return path, nil
+}
@@ -0,0 +1,131 @@
+package codex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordedConfigValue returns the value of a --config key=value argument.
+func recordedConfigValue(args []string, key string) (string, bool) {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--config" && strings.HasPrefix(args[i+1], key+"=") {
+			return strings.TrimPrefix(args[i+1], key+"="), true
+		}
+	}
+	return "", false
+}
+
+func TestWithInstructions(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.StartThread(WithInstructions("Always answer in haiku."))
+	ctx := testContext(t)
+
+	if _, err := thread.Run(ctx, Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	value, ok := recordedConfigValue(readRecordedArgs(t, argsFile), "experimental_instructions_file")
+	if !ok {
+		t.Fatalf("expected instructions file config in args, got %q", readRecordedArgs(t, argsFile))
+	}
+	path := strings.Trim(value, `"`)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read instructions file: %v", err)
+	}
+	if string(data) != "Always answer in haiku." {
+		t.Errorf("unexpected instructions file content %q", data)
+	}
+
+	if _, err := thread.Run(ctx, Text("again")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if again, _ := recordedConfigValue(readRecordedArgs(t, argsFile), "experimental_instructions_file"); again != value {
+		t.Errorf("expected instructions file to be reused, got %s then %s", value, again)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected instructions file to be removed on Close, stat err: %v", err)
+	}
+}
+
+func TestWithoutInstructions(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script)
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, ok := recordedConfigValue(readRecordedArgs(t, argsFile), "experimental_instructions_file"); ok {
+		t.Error("expected no instructions file config without WithInstructions")
+	}
+}
+
+func TestWithInstructionsFile(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	path := filepath.Join(t.TempDir(), "AGENTS.md")
+	if err := os.WriteFile(path, []byte("Be terse."), 0o644); err != nil {
+		t.Fatalf("failed to write instructions file: %v", err)
+	}
+
+	thread := newFakeThread(t, script, WithInstructionsFile(path))
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	value, ok := recordedConfigValue(readRecordedArgs(t, argsFile), "experimental_instructions_file")
+	if !ok || value != tomlString(path) {
+		t.Errorf("expected instructions file %s to be passed directly, got %q", tomlString(path), value)
+	}
+
+	var invalidInput *ErrInvalidInput
+	both := newFakeThread(t, script, WithInstructions("Be verbose."), WithInstructionsFile(path))
+	if _, err := both.Run(testContext(t), Text("hello")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput when combining inline and file instructions, got %v", err)
+	}
+
+	missing := newFakeThread(t, script, WithInstructionsFile(filepath.Join(t.TempDir(), "missing.md")))
+	if _, err := missing.Run(testContext(t), Text("hello")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for a missing instructions file, got %v", err)
+	}
+}
+
+func TestWithInstructionsFileRelativePath(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "AGENTS.md"), []byte("Be terse."), 0o644); err != nil {
+		t.Fatalf("failed to write instructions file: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	// The CLI runs in a different directory, so a relative path would
+	// resolve to the wrong file.
+	thread := newFakeThread(t, script, WithInstructionsFile("AGENTS.md"), WithWorkingDirectory(t.TempDir()))
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want, err := filepath.Abs("AGENTS.md")
+	if err != nil {
+		t.Fatalf("failed to resolve instructions file: %v", err)
+	}
+	value, ok := recordedConfigValue(readRecordedArgs(t, argsFile), "experimental_instructions_file")
+	if !ok || value != tomlString(want) {
+		t.Errorf("expected absolute instructions file %s, got %q", tomlString(want), value)
+	}
+}
@@ -1,6 +1,7 @@
 package codex
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 )
@@ -75,12 +76,25 @@ type AgentMessageItem struct {
 	ID   string `json:"id"`
 	Type string `json:"type"`
 	// Text contains either natural-language text or JSON when structured output is requested.
+	// When the CLI streams a message as deltas, Text holds the text assembled
+	// so far.
 	Text string `json:"text"`
+	// Delta is the text appended by an item.updated event, when the CLI
+	// streams the message incrementally.
+	Delta string `json:"delta,omitempty"`
 }
 
 func (i *AgentMessageItem) itemType() ItemType { return ItemAgentMessage }
 func (i *AgentMessageItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *AgentMessageItem) MarshalJSON() ([]byte, error) {
+	type alias AgentMessageItem
+	a := alias(*i)
+	a.Type = string(ItemAgentMessage)
+	return json.Marshal(a)
+}
+
 // ReasoningItem captures the agent's reasoning summary.
 type ReasoningItem struct {
 	ID   string `json:"id"`
@@ -91,13 +105,23 @@ type ReasoningItem struct {
 func (i *ReasoningItem) itemType() ItemType { return ItemReasoning }
 func (i *ReasoningItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *ReasoningItem) MarshalJSON() ([]byte, error) {
+	type alias ReasoningItem
+	a := alias(*i)
+	a.Type = string(ItemReasoning)
+	return json.Marshal(a)
+}
+
 // CommandExecutionItem records a shell command executed by the agent.
 type CommandExecutionItem struct {
 	ID   string `json:"id"`
 	Type string `json:"type"`
 	// Command is the command line executed.
 	Command string `json:"command"`
-	// AggregatedOutput is the captured stdout and stderr.
+	// AggregatedOutput is the captured stdout and stderr. On item.updated
+	// events it holds the output produced so far, so successive updates can
+	// be rendered as a live terminal.
 	AggregatedOutput string `json:"aggregated_output"`
 	// ExitCode is set when the command exits.
 	ExitCode *int `json:"exit_code,omitempty"`
@@ -108,10 +132,20 @@ type CommandExecutionItem struct {
 func (i *CommandExecutionItem) itemType() ItemType { return ItemCommandExecution }
 func (i *CommandExecutionItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *CommandExecutionItem) MarshalJSON() ([]byte, error) {
+	type alias CommandExecutionItem
+	a := alias(*i)
+	a.Type = string(ItemCommandExecution)
+	return json.Marshal(a)
+}
+
 // FileUpdateChange describes an individual file operation.
 type FileUpdateChange struct {
 	Path string          `json:"path"`
 	Kind PatchChangeKind `json:"kind"`
+	// Diff is the unified diff of the change, when the CLI reports it.
+	Diff string `json:"diff,omitempty"`
 }
 
 // FileChangeItem aggregates a set of file modifications.
@@ -127,12 +161,70 @@ type FileChangeItem struct {
 func (i *FileChangeItem) itemType() ItemType { return ItemFileChange }
 func (i *FileChangeItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *FileChangeItem) MarshalJSON() ([]byte, error) {
+	type alias FileChangeItem
+	a := alias(*i)
+	a.Type = string(ItemFileChange)
+	return json.Marshal(a)
+}
+
 // McpContentBlock represents content returned by an MCP tool.
 type McpContentBlock struct {
 	Type string `json:"type"`
 	Text string `json:"text,omitempty"`
 	// Additional fields may be present depending on the content type.
 	Data json.RawMessage `json:"data,omitempty"`
+	// MimeType is set for image and audio content.
+	MimeType string `json:"mimeType,omitempty"`
+	// Resource holds the embedded resource for resource content.
+	Resource json.RawMessage `json:"resource,omitempty"`
+}
+
+// McpResource is an embedded resource returned in an MCP content block.
+type McpResource struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	// Text is set for text resources.
+	Text string `json:"text,omitempty"`
+	// Blob is the base64-encoded payload for binary resources.
+	Blob string `json:"blob,omitempty"`
+}
+
+// AsText returns the text of a text content block.
+func (b *McpContentBlock) AsText() (string, bool) {
+	if b.Type != "text" {
+		return "", false
+	}
+	return b.Text, true
+}
+
+// AsImage returns the MIME type and decoded bytes of an image content block.
+func (b *McpContentBlock) AsImage() (mimeType string, data []byte, ok bool) {
+	if b.Type != "image" {
+		return "", nil, false
+	}
+	var encoded string
+	if err := json.Unmarshal(b.Data, &encoded); err != nil {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+	return b.MimeType, data, true
+}
+
+// AsResource returns the embedded resource of a resource content block.
+func (b *McpContentBlock) AsResource() (McpResource, bool) {
+	if b.Type != "resource" || len(b.Resource) == 0 {
+		return McpResource{}, false
+	}
+	var resource McpResource
+	if err := json.Unmarshal(b.Resource, &resource); err != nil {
+		return McpResource{}, false
+	}
+	return resource, true
 }
 
 // McpToolResult contains the result of an MCP tool call.
@@ -167,16 +259,42 @@ type McpToolCallItem struct {
 func (i *McpToolCallItem) itemType() ItemType { return ItemMcpToolCall }
 func (i *McpToolCallItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *McpToolCallItem) MarshalJSON() ([]byte, error) {
+	type alias McpToolCallItem
+	a := alias(*i)
+	a.Type = string(ItemMcpToolCall)
+	return json.Marshal(a)
+}
+
+// WebSearchResult is a page returned by a web search.
+type WebSearchResult struct {
+	URL     string `json:"url"`
+	Title   string `json:"title,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
 // WebSearchItem captures a web search request.
 type WebSearchItem struct {
 	ID    string `json:"id"`
 	Type  string `json:"type"`
 	Query string `json:"query"`
+	// Results lists the pages found, when the CLI reports them. Use them to
+	// cite sources.
+	Results []WebSearchResult `json:"results,omitempty"`
 }
 
 func (i *WebSearchItem) itemType() ItemType { return ItemWebSearch }
 func (i *WebSearchItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *WebSearchItem) MarshalJSON() ([]byte, error) {
+	type alias WebSearchItem
+	a := alias(*i)
+	a.Type = string(ItemWebSearch)
+	return json.Marshal(a)
+}
+
 // TodoItem describes a single checklist item.
 type TodoItem struct {
 	Text      string `json:"text"`
@@ -193,6 +311,14 @@ type TodoListItem struct {
 func (i *TodoListItem) itemType() ItemType { return ItemTodoList }
 func (i *TodoListItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *TodoListItem) MarshalJSON() ([]byte, error) {
+	type alias TodoListItem
+	a := alias(*i)
+	a.Type = string(ItemTodoList)
+	return json.Marshal(a)
+}
+
 // ErrorItem reflects a non-fatal error surfaced to the user.
 type ErrorItem struct {
 	ID      string `json:"id"`
@@ -203,6 +329,14 @@ type ErrorItem struct {
 func (i *ErrorItem) itemType() ItemType { return ItemError }
 func (i *ErrorItem) GetID() string      { return i.ID }
 
+// MarshalJSON encodes the item with its type discriminator set.
+func (i *ErrorItem) MarshalJSON() ([]byte, error) {
+	type alias ErrorItem
+	a := alias(*i)
+	a.Type = string(ItemError)
+	return json.Marshal(a)
+}
+
 // UnknownItem preserves unrecognized item payloads.
 type UnknownItem struct {
 	ItemType string          `json:"type"`
@@ -212,6 +346,16 @@ type UnknownItem struct {
 func (i *UnknownItem) itemType() ItemType { return ItemType(i.ItemType) }
 func (i *UnknownItem) GetID() string      { return "" }
 
+// MarshalJSON returns the preserved raw payload.
+func (i *UnknownItem) MarshalJSON() ([]byte, error) {
+	if len(i.Raw) > 0 {
+		return i.Raw, nil
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+	}{Type: i.ItemType})
+}
+
 // unmarshalThreadItem decodes a thread item into the corresponding Go type.
 func unmarshalThreadItem(data []byte) (ThreadItem, error) {
 	var discriminator struct {
@@ -0,0 +1,61 @@
+package codex
+
+import (
+	"regexp"
+	"strings"
+)
+
+// modelFamilies maps a model family to the newest model of that family known
+// to this SDK release. It is a static table: the installed CLI is not
+// consulted, so it must be updated by hand as models are released.
+var modelFamilies = map[string]string{
+	"gpt-5":            "gpt-5.1",
+	"gpt-5-codex":      "gpt-5.1-codex",
+	"gpt-5-codex-mini": "gpt-5.1-codex-mini",
+}
+
+// modelUnavailablePattern recognizes errors reporting that the requested
+// model does not exist or is not supported, such as "unsupported model x" or
+// "The model `x` does not exist". It deliberately does not match transient
+// failures like "model is overloaded and unavailable", which a different
+// model should not be tried for.
+var modelUnavailablePattern = regexp.MustCompile(`(?i)model_not_found|unsupported model|\bmodel\s+\S+\s+(?:does not exist|not found)`)
+
+// resolveModel returns the model passed to the CLI for the thread options.
+//
+// An explicit Model always wins. When only ModelFamily is set, it resolves to
+// the newest known model of the family, or is passed through unchanged for
+// families the SDK does not know. Setting both is an error unless Model
+// belongs to ModelFamily.
+func resolveModel(opts ThreadOptions) (string, error) {
+	if opts.ModelFamily == "" {
+		return opts.Model, nil
+	}
+
+	if opts.Model != "" {
+		if !inModelFamily(opts.Model, opts.ModelFamily) {
+			return "", &ErrInvalidInput{
+				Field:  "model",
+				Value:  opts.Model,
+				Reason: "does not belong to model family " + opts.ModelFamily,
+			}
+		}
+		return opts.Model, nil
+	}
+
+	if model, ok := modelFamilies[opts.ModelFamily]; ok {
+		return model, nil
+	}
+	return opts.ModelFamily, nil
+}
+
+// inModelFamily reports whether model is family itself, the model the family
+// resolves to, or a variant of either (such as a dated snapshot).
+func inModelFamily(model, family string) bool {
+	for _, base := range []string{family, modelFamilies[family]} {
+		if base != "" && (model == base || strings.HasPrefix(model, base+"-")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package codex
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ThreadOption
+		want    string
+		wantErr bool
+	}{
+		{name: "none", want: ""},
+		{name: "model_only", opts: []ThreadOption{WithModel("o3")}, want: "o3"},
+		{name: "known_family", opts: []ThreadOption{WithModelFamily("gpt-5-codex")}, want: "gpt-5.1-codex"},
+		{name: "unknown_family_passthrough", opts: []ThreadOption{WithModelFamily("my-model")}, want: "my-model"},
+		{name: "model_in_family", opts: []ThreadOption{WithModelFamily("gpt-5"), WithModel("gpt-5-2025-08-07")}, want: "gpt-5-2025-08-07"},
+		{name: "model_is_resolved_member", opts: []ThreadOption{WithModelFamily("gpt-5"), WithModel("gpt-5.1")}, want: "gpt-5.1"},
+		{name: "conflict", opts: []ThreadOption{WithModelFamily("gpt-5-codex"), WithModel("gpt-4.1")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveModel(applyThreadOptions(tt.opts))
+			if tt.wantErr {
+				var invalidInput *ErrInvalidInput
+				if !errors.As(err, &invalidInput) {
+					t.Fatalf("expected ErrInvalidInput, got model=%q err=%v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected model %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunModelFamilyArgs(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithModelFamily("gpt-5-codex"))
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if args := readRecordedArgs(t, argsFile); !containsArgPair(args, "--model", "gpt-5.1-codex") {
+		t.Errorf("expected resolved model in args, got %q", args)
+	}
+
+	conflicting := newFakeThread(t, script, WithModelFamily("gpt-5-codex"), WithModel("gpt-4.1"))
+	var invalidInput *ErrInvalidInput
+	if _, err := conflicting.Run(testContext(t), Text("hello")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for conflicting model, got %v", err)
+	}
+}
@@ -1,5 +1,12 @@
 package codex
 
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+)
+
 // SandboxMode controls the filesystem sandbox granted to the agent.
 type SandboxMode string
 
@@ -42,6 +49,32 @@ const (
 	ReasoningXHigh ModelReasoningEffort = "xhigh"
 )
 
+// ModelVerbosity controls the length of model responses independently of
+// reasoning effort.
+type ModelVerbosity string
+
+const (
+	// VerbosityLow produces terse responses.
+	VerbosityLow ModelVerbosity = "low"
+	// VerbosityMedium produces responses of moderate length.
+	VerbosityMedium ModelVerbosity = "medium"
+	// VerbosityHigh produces detailed responses.
+	VerbosityHigh ModelVerbosity = "high"
+)
+
+// ShellEnvironmentPolicy controls which host environment variables the
+// sandboxed shell inherits.
+type ShellEnvironmentPolicy string
+
+const (
+	// ShellEnvironmentAll inherits the full host environment.
+	ShellEnvironmentAll ShellEnvironmentPolicy = "all"
+	// ShellEnvironmentCore inherits only core variables such as HOME and PATH.
+	ShellEnvironmentCore ShellEnvironmentPolicy = "core"
+	// ShellEnvironmentNone starts the shell with an empty environment.
+	ShellEnvironmentNone ShellEnvironmentPolicy = "none"
+)
+
 // CodexOptions configures a Codex client.
 type CodexOptions struct {
 	// CodexPath points to a specific codex binary. When empty, the SDK
@@ -59,6 +92,80 @@ type CodexOptions struct {
 	// Env specifies environment variables passed to the Codex CLI process.
 	// When provided, the SDK will not inherit variables from os.Environ().
 	Env map[string]string
+
+	// EnvFile is a dotenv file whose variables are added to the CLI's
+	// environment.
+	EnvFile string
+
+	// CommandOutputLimit caps the bytes of command output kept per command
+	// execution item. Zero means no limit.
+	CommandOutputLimit int
+
+	// DisallowUnknownFields fails turns whose events contain fields the SDK
+	// does not know.
+	DisallowUnknownFields bool
+
+	// ExtraHeaders are added to every request the CLI sends to the model
+	// provider.
+	ExtraHeaders map[string]string
+
+	// HomeDir replaces the user's home directory for the CLI and for the
+	// SDK's own lookups under ~/.codex.
+	HomeDir string
+
+	// Originator identifies the client to the CLI and the API. It takes
+	// precedence over CODEX_INTERNAL_ORIGINATOR_OVERRIDE in the environment.
+	Originator string
+
+	// RequestIDKey is the context key whose value is exported to the CLI
+	// process as CODEX_REQUEST_ID on each run.
+	RequestIDKey any
+
+	// BinaryChecksum is the expected hex-encoded SHA-256 digest of the
+	// resolved codex binary. It is verified once when the client is created.
+	BinaryChecksum string
+
+	// Logger receives diagnostic warnings from the SDK. When nil, nothing
+	// is logged.
+	Logger *slog.Logger
+
+	// StderrLimit caps how many trailing bytes of CLI stderr are kept for
+	// ErrExecFailed. When zero, the last 64 KiB are kept.
+	StderrLimit int
+
+	// ExtraArgs are appended to every codex exec invocation.
+	ExtraArgs []string
+
+	// StartupTimeout bounds the time from starting the CLI to its first
+	// event. Zero disables the startup timeout.
+	StartupTimeout time.Duration
+	// MaxConcurrency caps how many codex processes the client runs at once.
+	// Zero means no limit.
+	MaxConcurrency int
+	// EventFilter selects which item events streamed turns deliver.
+	EventFilter func(ThreadEvent) bool
+	// ProcessGroup runs each codex process in its own process group so that
+	// cancellation terminates its descendants too.
+	ProcessGroup bool
+	// StartSpan starts tracing spans around turns and codex processes.
+	StartSpan func(ctx context.Context, name string) (context.Context, func(error))
+	// PromptRewriter rewrites each prompt before it is sent to the CLI.
+	PromptRewriter func(string) string
+	// TelemetryDisabled keeps the SDK originator out of the CLI's
+	// environment.
+	TelemetryDisabled bool
+	// CompressedEventLog is the path of a gzip file receiving the raw
+	// events of every turn.
+	CompressedEventLog string
+	// PreRun is called with the arguments of every codex process before it
+	// starts.
+	PreRun func(ctx context.Context, args ExecArgs)
+	// PostRun is called with the result of every codex process once it
+	// ends.
+	PostRun func(ctx context.Context, turn *Turn, err error)
+	// Clock provides time for timeouts and event timestamps. Nil uses the
+	// system clock.
+	Clock Clock
 }
 
 // Option is a functional option for configuring a Codex client.
@@ -95,10 +202,284 @@ func WithAPIKey(key string) Option {
 }
 
 // WithEnv sets custom environment variables for the CLI process.
-// When set, os.Environ() will not be inherited.
+// When set, os.Environ() will not be inherited. The map is copied, so later
+// changes to env do not affect the client.
 func WithEnv(env map[string]string) Option {
 	return func(o *CodexOptions) {
-		o.Env = env
+		o.Env = maps.Clone(env)
+	}
+}
+
+// WithEnvFile adds the variables of a dotenv file to the CLI's environment.
+// They are layered over the inherited os.Environ(), or, combined with WithEnv,
+// fill in variables the WithEnv map does not set. Lines hold KEY=VALUE,
+// optionally prefixed with "export "; blank lines and lines starting with #
+// are skipped. Values may be single-quoted, double-quoted with backslash
+// escapes, or unquoted with an optional trailing " # comment". The file is
+// read by New, which fails with *ErrInvalidInput if it is missing or has a
+// malformed line. No-op when path is empty.
+func WithEnvFile(path string) Option {
+	return func(o *CodexOptions) {
+		if path != "" {
+			o.EnvFile = path
+		}
+	}
+}
+
+// WithCommandOutputLimit keeps only the first n bytes of each command's
+// AggregatedOutput as events are parsed, followed by a marker saying how much
+// was dropped, to bound memory for agents running verbose commands. The cut
+// never splits a UTF-8 character. No-op when n is not positive.
+func WithCommandOutputLimit(n int) Option {
+	return func(o *CodexOptions) {
+		if n > 0 {
+			o.CommandOutputLimit = n
+		}
+	}
+}
+
+// WithDisallowUnknownFields fails turns with a parse error when an event or
+// item from the CLI carries a JSON field the SDK's types do not declare. Use
+// it in development and CI to notice protocol additions in new CLI versions;
+// by default unknown fields are ignored. Items of unknown types are still
+// returned as UnknownItem.
+func WithDisallowUnknownFields() Option {
+	return func(o *CodexOptions) {
+		o.DisallowUnknownFields = true
+	}
+}
+
+// WithExtraHeaders adds HTTP headers to the CLI's requests to the model API,
+// for proxies and gateways that need more than the API key. Codex only sends
+// http_headers for model providers declared in config, so the SDK declares a
+// "codex_sdk" provider for the OpenAI Responses API with these headers and
+// selects it with model_provider. The provider's base URL is WithBaseURL or
+// the OPENAI_BASE_URL of the CLI's environment; turns fail with
+// *ErrInvalidInput when neither is set. It uses the WithAPIKey key when set
+// and otherwise the same credentials as the built-in openai provider.
+//
+// When model_provider is already set through WithRawConfigTOML or a --config
+// argument, the SDK leaves it alone and sends no headers; set http_headers
+// for that provider instead. The map is copied.
+func WithExtraHeaders(headers map[string]string) Option {
+	return func(o *CodexOptions) {
+		o.ExtraHeaders = maps.Clone(headers)
+	}
+}
+
+// WithHomeDir uses dir as the home directory instead of $HOME, for daemons
+// and service accounts without a usable one. The CLI runs with HOME set to
+// dir, so it reads credentials and config from and persists sessions under
+// dir/.codex, and the SDK looks for sessions there too. CODEX_HOME, when set,
+// still takes precedence over dir/.codex. No-op when dir is empty.
+func WithHomeDir(dir string) Option {
+	return func(o *CodexOptions) {
+		if dir != "" {
+			o.HomeDir = dir
+		}
+	}
+}
+
+// WithOriginator sets the originator the CLI reports for requests, exported
+// as CODEX_INTERNAL_ORIGINATOR_OVERRIDE. The originator is chosen in order
+// from this option, the variable in the WithEnv map or, without WithEnv, the
+// inherited environment, and finally the Go SDK's default. No-op when name is
+// empty.
+func WithOriginator(name string) Option {
+	return func(o *CodexOptions) {
+		if name != "" {
+			o.Originator = name
+		}
+	}
+}
+
+// WithTelemetryDisabled stops the SDK from identifying itself to the CLI: the
+// Go SDK originator is not set in CODEX_INTERNAL_ORIGINATOR_OVERRIDE, and the
+// variable is removed if it is inherited or set through WithEnv or
+// WithEnvFile. It takes precedence over WithOriginator.
+func WithTelemetryDisabled() Option {
+	return func(o *CodexOptions) {
+		o.TelemetryDisabled = true
+	}
+}
+
+// WithRequestIDFromContext exports the value stored in the run context under
+// key to the CLI process as CODEX_REQUEST_ID, tying each invocation back to a
+// trace. Values must be strings or implement fmt.Stringer; other values and
+// missing keys are ignored.
+func WithRequestIDFromContext(key any) Option {
+	return func(o *CodexOptions) {
+		o.RequestIDKey = key
+	}
+}
+
+// WithBinaryChecksum verifies that the resolved codex binary matches the given
+// hex-encoded SHA-256 digest before the client is used. The check runs once in
+// New, not on every turn.
+func WithBinaryChecksum(hexDigest string) Option {
+	return func(o *CodexOptions) {
+		o.BinaryChecksum = hexDigest
+	}
+}
+
+// WithLogger sets the logger used for SDK warnings, such as a resumed thread
+// reporting an unexpected ID.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *CodexOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithStderrLimit sets how many trailing bytes of CLI stderr are retained for
+// ErrExecFailed.Stderr. Earlier output is discarded so that a process writing
+// megabytes of stderr cannot exhaust memory. No-op when n is not positive.
+func WithStderrLimit(n int) Option {
+	return func(o *CodexOptions) {
+		if n > 0 {
+			o.StderrLimit = n
+		}
+	}
+}
+
+// WithExtraArgs appends arguments to every codex exec invocation, after the
+// flags generated by the SDK and before the resume subcommand. Use it for CLI
+// flags the SDK does not model yet; the arguments are not validated.
+func WithExtraArgs(args ...string) Option {
+	return func(o *CodexOptions) {
+		o.ExtraArgs = append(o.ExtraArgs, args...)
+	}
+}
+
+// WithStartupTimeout kills the CLI and fails the turn with ErrStartupTimeout
+// if no event arrives within d of starting the process. It catches binaries
+// that hang before doing any work, independently of how long the turn itself
+// may take. No-op when d is not positive.
+func WithStartupTimeout(d time.Duration) Option {
+	return func(o *CodexOptions) {
+		if d > 0 {
+			o.StartupTimeout = d
+		}
+	}
+}
+
+// WithMaxConcurrency limits the client to n concurrently running codex
+// processes across all of its threads. Further turns wait for a running one
+// to finish; a turn whose context ends while waiting fails with the
+// context's error. No-op when n is not positive.
+func WithMaxConcurrency(n int) Option {
+	return func(o *CodexOptions) {
+		if n > 0 {
+			o.MaxConcurrency = n
+		}
+	}
+}
+
+// WithEventFilter drops item events for which keep returns false from the
+// Events channel of RunStreamed and RunRaw, for example to hide reasoning
+// from a UI. Thread, turn, and error events, which carry completion and
+// usage, are always delivered. Run and the helpers built on it see every
+// event, so their results are unaffected.
+func WithEventFilter(keep func(ThreadEvent) bool) Option {
+	return func(o *CodexOptions) {
+		o.EventFilter = keep
+	}
+}
+
+// WithPromptRewriter passes every prompt through rewrite before it is written
+// to the CLI, for example to redact secrets or add boilerplate in one place.
+// It runs after the input is normalized, so it sees the text parts joined and
+// any WithContext preamble prepended; image paths are not passed through it.
+// RunRaw sends its stdin unchanged and is not affected.
+func WithPromptRewriter(rewrite func(string) string) Option {
+	return func(o *CodexOptions) {
+		o.PromptRewriter = rewrite
+	}
+}
+
+// WithCompressedEventLog writes the raw JSONL events of every turn the client
+// runs to a gzip-compressed file at path, for keeping long sessions on disk
+// compactly. New creates or truncates the file. The gzip stream is finished
+// at the end of every turn, whether it succeeded or failed, so the file is
+// always readable with gzip.NewReader; each turn adds a gzip member. Close
+// closes the file and reports any error that occurred while writing the log.
+// No-op when path is empty.
+func WithCompressedEventLog(path string) Option {
+	return func(o *CodexOptions) {
+		if path != "" {
+			o.CompressedEventLog = path
+		}
+	}
+}
+
+// WithPreRun calls hook just before each codex process starts, with the
+// assembled arguments, for example to log the exact invocation. A turn
+// usually runs one process, but one retried by WithModelFallback or
+// WithAutoResumeFallback runs one per attempt and calls hook for each, so
+// count turns by the Run calls rather than by hook calls. args includes the
+// API key. The hook runs on the goroutine starting the process and must not
+// modify the slices and maps in args.
+func WithPreRun(hook func(ctx context.Context, args ExecArgs)) Option {
+	return func(o *CodexOptions) {
+		o.PreRun = hook
+	}
+}
+
+// WithPostRun calls hook once each codex process the WithPreRun hook saw
+// ends, streamed or not, with the items and usage collected and the error the
+// process ended with, including a turn.failed event. turn is nil when the
+// process could not be started. Like WithPreRun, it fires once per attempt
+// of a retried turn, so a failed attempt is reported before the retry
+// starts. For streamed turns the hook runs before Wait returns. Both hooks
+// receive the context the turn was started with.
+func WithPostRun(hook func(ctx context.Context, turn *Turn, err error)) Option {
+	return func(o *CodexOptions) {
+		o.PostRun = hook
+	}
+}
+
+// WithClock replaces the system clock used for the startup and idle
+// timeouts, event ReceivedAt timestamps, and backpressure measurements, so
+// tests can drive timing-dependent behavior with a fake clock instead of
+// sleeping. WithProgress ticks keep using real time.
+func WithClock(clock Clock) Option {
+	return func(o *CodexOptions) {
+		o.Clock = clock
+	}
+}
+
+// WithProcessGroup starts each codex process in a new process group and, when
+// a turn is cancelled or interrupted, sends SIGTERM to the whole group rather
+// than only the CLI, killing the group if it is still running a second later.
+// Commands the agent started, such as builds or test servers, then do not
+// outlive the turn. On Windows the option has no effect.
+func WithProcessGroup() Option {
+	return func(o *CodexOptions) {
+		o.ProcessGroup = true
+	}
+}
+
+// WithStartSpan traces turns through start, which is called with the name of
+// each span and returns the context for work inside the span and a function
+// ending it with the span's error, or nil on success. Two spans are started
+// per turn: "codex.turn" covers the whole turn, and "codex.exec", started
+// with the turn span's context, covers the codex process from spawn to exit.
+// Adapting start to OpenTelemetry takes a few lines:
+//
+//	codex.WithStartSpan(func(ctx context.Context, name string) (context.Context, func(error)) {
+//		ctx, span := tracer.Start(ctx, name)
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	})
+//
+// The context returned by start must be derived from the one passed in.
+func WithStartSpan(start func(ctx context.Context, name string) (context.Context, func(error))) Option {
+	return func(o *CodexOptions) {
+		o.StartSpan = start
 	}
 }
 
@@ -107,6 +488,10 @@ type ThreadOptions struct {
 	// Model selects the model identifier to run the agent with.
 	Model string
 
+	// ModelFamily selects a model of a family from the SDK's built-in table
+	// when Model is not set.
+	ModelFamily string
+
 	// SandboxMode controls the filesystem sandbox granted to the agent.
 	SandboxMode SandboxMode
 
@@ -116,13 +501,37 @@ type ThreadOptions struct {
 	// SkipGitRepoCheck skips the Git repository check (--skip-git-repo-check).
 	SkipGitRepoCheck bool
 
+	// Ephemeral disables session persistence (--ephemeral).
+	Ephemeral bool
+
 	// ModelReasoningEffort sets the reasoning intensity of the model.
 	ModelReasoningEffort ModelReasoningEffort
 
+	// DisableReasoningSummary stops the model from summarizing its
+	// reasoning, so no reasoning items are produced.
+	DisableReasoningSummary bool
+
+	// ModelVerbosity sets the response verbosity of the model.
+	ModelVerbosity ModelVerbosity
+
+	// ModelContextWindow overrides the model's context window size in tokens.
+	// Use a pointer to distinguish between unset and zero.
+	ModelContextWindow *int
+
 	// NetworkAccessEnabled enables network access for the agent.
 	// Use a pointer to distinguish between unset and false.
 	NetworkAccessEnabled *bool
 
+	// SandboxExcludeTmpdir removes the directory named by $TMPDIR from the
+	// workspace-write sandbox's writable roots.
+	// Use a pointer to distinguish between unset and false.
+	SandboxExcludeTmpdir *bool
+
+	// SandboxExcludeSlashTmp removes /tmp from the workspace-write
+	// sandbox's writable roots.
+	// Use a pointer to distinguish between unset and false.
+	SandboxExcludeSlashTmp *bool
+
 	// WebSearchEnabled enables web search for the agent.
 	// Use a pointer to distinguish between unset and false.
 	WebSearchEnabled *bool
@@ -132,6 +541,51 @@ type ThreadOptions struct {
 
 	// AdditionalDirectories specifies additional directories accessible to the agent.
 	AdditionalDirectories []string
+
+	// ShellEnvironmentPolicy controls which host environment variables the
+	// agent's shell inherits.
+	ShellEnvironmentPolicy ShellEnvironmentPolicy
+
+	// WritableRoots grants write access to extra directories under the
+	// workspace-write sandbox.
+	WritableRoots []string
+
+	// StrictThreadID fails a turn when the CLI reports a thread ID that
+	// differs from the thread's existing ID instead of logging a warning.
+	StrictThreadID bool
+
+	// SessionID is the ID of the session the thread runs in.
+	SessionID string
+
+	// ModelFallback lists models to retry a turn with, in order, when the
+	// model is unavailable.
+	ModelFallback []string
+
+	// AutoResumeFallback starts a new session when the session being
+	// resumed cannot be found.
+	AutoResumeFallback bool
+
+	// StrictEventValidation fails a turn whose events violate the ordering
+	// invariants checked by ValidateEventSequence.
+	StrictEventValidation bool
+
+	// Instructions replaces the agent's base instructions for the thread.
+	Instructions string
+
+	// InstructionsFile points at an existing file whose contents replace the
+	// agent's base instructions. It cannot be combined with Instructions.
+	InstructionsFile string
+
+	// GitWorktreeBranch runs the thread in a temporary git worktree on a new
+	// branch with this name.
+	GitWorktreeBranch string
+
+	// DefaultTurnOptions are applied to every turn before the options passed
+	// to Run, so per-call options override them.
+	DefaultTurnOptions []TurnOption
+
+	// RawConfigTOML is a TOML config snippet passed to the CLI as overrides.
+	RawConfigTOML string
 }
 
 // ThreadOption is a functional option for configuring a Thread.
@@ -139,6 +593,10 @@ type ThreadOption func(*ThreadOptions)
 
 // WithModel sets the model identifier.
 // No-op when model is empty.
+//
+// The SDK does not validate model names: the CLI has no command that lists
+// the models available to an account, so an unknown model only surfaces as
+// an error from the turn.
 func WithModel(model string) ThreadOption {
 	return func(o *ThreadOptions) {
 		if model != "" {
@@ -147,6 +605,22 @@ func WithModel(model string) ThreadOption {
 	}
 }
 
+// WithModelFamily selects a model by family (for example "gpt-5") instead of
+// pinning a model. The family resolves through a fixed table compiled into
+// this SDK release, not by asking the installed CLI, so it does not pick up
+// models released later and does not check that the CLI or account supports
+// the result; combine it with WithModelFallback to cover that. Families
+// missing from the table are passed to the CLI as the model name. When
+// WithModel is also set it takes precedence, but it must name a model of the
+// family. No-op when family is empty.
+func WithModelFamily(family string) ThreadOption {
+	return func(o *ThreadOptions) {
+		if family != "" {
+			o.ModelFamily = family
+		}
+	}
+}
+
 // WithSandboxMode sets the sandbox mode.
 func WithSandboxMode(mode SandboxMode) ThreadOption {
 	return func(o *ThreadOptions) {
@@ -171,6 +645,16 @@ func WithSkipGitRepoCheck() ThreadOption {
 	}
 }
 
+// WithEphemeral runs the thread without persisting its session under
+// ~/.codex/sessions, for workloads that must not leave transcripts on disk.
+// Thread.ID is still populated in memory, but an ephemeral thread cannot be
+// resumed once its Thread value is gone.
+func WithEphemeral() ThreadOption {
+	return func(o *ThreadOptions) {
+		o.Ephemeral = true
+	}
+}
+
 // WithModelReasoningEffort sets the reasoning effort level.
 func WithModelReasoningEffort(effort ModelReasoningEffort) ThreadOption {
 	return func(o *ThreadOptions) {
@@ -178,6 +662,37 @@ func WithModelReasoningEffort(effort ModelReasoningEffort) ThreadOption {
 	}
 }
 
+// WithDisableReasoning minimizes reasoning for simple tasks where speed and
+// cost matter more than depth: it sets the reasoning effort to
+// ReasoningMinimal and turns off reasoning summaries. WithReasoningEffort on
+// a turn still overrides the effort.
+func WithDisableReasoning() ThreadOption {
+	return func(o *ThreadOptions) {
+		o.ModelReasoningEffort = ReasoningMinimal
+		o.DisableReasoningSummary = true
+	}
+}
+
+// WithModelVerbosity sets the response verbosity level.
+//
+// Verbosity and reasoning effort are the only generation controls codex
+// exec accepts: it has no flags or config keys for sampling parameters such
+// as temperature or top_p, so the SDK offers no options for them.
+func WithModelVerbosity(level ModelVerbosity) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.ModelVerbosity = level
+	}
+}
+
+// WithModelContextWindow sets the context window size, in tokens, of the
+// model. The CLI knows the window of OpenAI models; set it for custom or
+// self-hosted models reached through WithBaseURL. tokens must be positive.
+func WithModelContextWindow(tokens int) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.ModelContextWindow = &tokens
+	}
+}
+
 // WithNetworkAccess enables or disables network access.
 func WithNetworkAccess(enabled bool) ThreadOption {
 	return func(o *ThreadOptions) {
@@ -185,6 +700,23 @@ func WithNetworkAccess(enabled bool) ThreadOption {
 	}
 }
 
+// WithSandboxExcludeTmpdir sets whether the workspace-write sandbox withholds
+// write access to the directory named by $TMPDIR, which it grants by
+// default. Keep it writable for builds that put intermediate files there.
+func WithSandboxExcludeTmpdir(exclude bool) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.SandboxExcludeTmpdir = &exclude
+	}
+}
+
+// WithSandboxExcludeSlashTmp sets whether the workspace-write sandbox
+// withholds write access to /tmp, which it grants by default.
+func WithSandboxExcludeSlashTmp(exclude bool) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.SandboxExcludeSlashTmp = &exclude
+	}
+}
+
 // WithWebSearch enables or disables web search.
 func WithWebSearch(enabled bool) ThreadOption {
 	return func(o *ThreadOptions) {
@@ -193,6 +725,13 @@ func WithWebSearch(enabled bool) ThreadOption {
 }
 
 // WithApprovalPolicy sets the approval policy.
+//
+// codex exec has no channel for answering approval requests: the SDK never
+// receives approval events and the CLI does not wait on the SDK for a
+// decision. Nor can a caller answer prompts through stdin: the CLI reads
+// it only for the prompt, and the SDK closes it once the prompt is written.
+// To bound a turn that stops making progress for any reason, use
+// WithIdleTimeout.
 func WithApprovalPolicy(policy ApprovalMode) ThreadOption {
 	return func(o *ThreadOptions) {
 		o.ApprovalPolicy = policy
@@ -206,11 +745,216 @@ func WithAdditionalDirectories(dirs ...string) ThreadOption {
 	}
 }
 
+// WithShellEnvironmentPolicy sets which host environment variables the
+// agent's shell inherits.
+func WithShellEnvironmentPolicy(policy ShellEnvironmentPolicy) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.ShellEnvironmentPolicy = policy
+	}
+}
+
+// WithWritableRoots grants the workspace-write sandbox write access to the
+// given directories in addition to the workspace.
+func WithWritableRoots(paths ...string) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.WritableRoots = append(o.WritableRoots, paths...)
+	}
+}
+
+// WithScratchDir gives the agent dir as an extra writable root for
+// intermediate files. It is equivalent to combining
+// WithSandboxMode(SandboxWorkspaceWrite) and WithWritableRoots(dir): codex
+// only honours writable roots in the workspace-write sandbox, so the working
+// directory is writable as well. The read-only sandbox has no way to grant
+// write access to a single directory. The directory must exist when a turn
+// starts.
+func WithScratchDir(dir string) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.SandboxMode = SandboxWorkspaceWrite
+		o.WritableRoots = append(o.WritableRoots, dir)
+	}
+}
+
+// WithStrictThreadID fails turns in which the CLI reports a thread ID that
+// differs from the one the thread already has, returning ErrThreadIDMismatch.
+// By default the mismatch is logged and the original ID is kept.
+func WithStrictThreadID() ThreadOption {
+	return func(o *ThreadOptions) {
+		o.StrictThreadID = true
+	}
+}
+
+// WithSessionID runs the thread in the CLI session with the given ID, a UUID
+// such as one agreed with an external system. codex exec cannot create a
+// session with a chosen ID, so the session must already exist: the first turn
+// of StartThread(WithSessionID(id)) resumes it, just like ResumeThread(id).
+// Turns fail with ErrInvalidInput when id is not a UUID, and with
+// ErrThreadIDMismatch when the thread was resumed under a different ID.
+func WithSessionID(id string) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.SessionID = id
+	}
+}
+
+// WithModelFallback retries a turn that fails with ErrModelUnavailable using
+// each of models in order, for example WithModel("gpt-5") followed by
+// WithModelFallback("gpt-4.1"). A turn is only retried while the failed
+// attempt ran no command, changed no file and called no MCP tool, so retries
+// never repeat side effects. Transient failures such as an overloaded model
+// are not ErrModelUnavailable and are not retried. Fallback applies to Run
+// and the helpers built on it; each turn starts again from the thread's
+// configured model. Empty names are ignored.
+func WithModelFallback(models ...string) ThreadOption {
+	return func(o *ThreadOptions) {
+		for _, model := range models {
+			if model != "" {
+				o.ModelFallback = append(o.ModelFallback, model)
+			}
+		}
+	}
+}
+
+// WithAutoResumeFallback makes Run, and the helpers built on it, start a new
+// session when the CLI cannot find or load the session the thread resumes,
+// instead of failing with ErrSessionNotFound. The turn is retried once
+// without the thread ID, the thread adopts the new session's ID, and a
+// warning is logged. Context from the lost session is not recovered.
+// Threads using WithSessionID never fall back.
+func WithAutoResumeFallback() ThreadOption {
+	return func(o *ThreadOptions) {
+		o.AutoResumeFallback = true
+	}
+}
+
+// WithStrictEventValidation checks each event read from the CLI against the
+// invariants of ValidateEventSequence and fails the turn with
+// ErrInvalidEventSequence on the first violation. Use it in tests and canary
+// deployments to catch protocol changes in new CLI versions.
+func WithStrictEventValidation() ThreadOption {
+	return func(o *ThreadOptions) {
+		o.StrictEventValidation = true
+	}
+}
+
+// WithInstructions replaces the agent's base instructions for every turn of
+// the thread. The text is written to a temporary file that is removed by
+// Codex.Close. No-op when text is empty.
+func WithInstructions(text string) ThreadOption {
+	return func(o *ThreadOptions) {
+		if text != "" {
+			o.Instructions = text
+		}
+	}
+}
+
+// WithInstructionsFile replaces the agent's base instructions with the
+// contents of the file at path. A relative path is resolved against the
+// process's working directory, not WithWorkingDirectory, and passed to the
+// CLI as an absolute path. The file must exist when a turn starts. Setting
+// both WithInstructions and WithInstructionsFile fails turns with
+// ErrInvalidInput. No-op when path is empty.
+func WithInstructionsFile(path string) ThreadOption {
+	return func(o *ThreadOptions) {
+		if path != "" {
+			o.InstructionsFile = path
+		}
+	}
+}
+
+// WithGitWorktree runs the thread in a temporary git worktree on a new branch
+// named branch, created from the HEAD of the repository containing the
+// working directory. The agent's changes stay isolated from the main checkout
+// for review. The worktree is created on the first turn and removed by
+// Codex.Close, discarding uncommitted changes; the branch and its commits are
+// kept. Turns fail with ErrInvalidInput outside a git repository.
+func WithGitWorktree(branch string) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.GitWorktreeBranch = branch
+	}
+}
+
+// WithRawConfigTOML passes a snippet of codex config.toml to the CLI, for
+// settings without a dedicated option:
+//
+//	codex.WithRawConfigTOML(`
+//	model_provider = "azure"
+//
+//	[model_providers.azure]
+//	base_url = "https://example.openai.azure.com/openai"
+//	`)
+//
+// Each key is sent as its own --config override, after those generated by
+// other options. The snippet must stick to the subset of TOML that maps onto
+// overrides: comments, [table] headers and key = value pairs whose value,
+// including any array or inline table, is written on one line. Arrays of
+// tables ([[name]]) and multi-line strings, arrays and inline tables fail
+// turns with ErrInvalidInput naming the line and the unsupported construct,
+// as does invalid TOML.
+func WithRawConfigTOML(toml string) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.RawConfigTOML = toml
+	}
+}
+
+// WithDefaultTurnOptions applies opts to every turn on the thread. Options
+// passed to an individual Run, RunStreamed, or similar call are applied
+// afterwards and take precedence.
+func WithDefaultTurnOptions(opts ...TurnOption) ThreadOption {
+	return func(o *ThreadOptions) {
+		o.DefaultTurnOptions = append(o.DefaultTurnOptions, opts...)
+	}
+}
+
 // TurnOptions configures a single turn when running the agent.
 type TurnOptions struct {
 	// OutputSchema describes the expected JSON structure when requesting
 	// structured output. The value must marshal to a JSON object.
 	OutputSchema any
+
+	// ModelReasoningEffort overrides the thread's reasoning effort for this
+	// turn only.
+	ModelReasoningEffort ModelReasoningEffort
+
+	// Context holds blocks of text prepended to the prompt for this turn.
+	Context []string
+
+	// PromptCacheKey pins the key used for prompt caching on this turn.
+	// Use a pointer to distinguish between unset and empty.
+	PromptCacheKey *string
+
+	// LastMessageFile is passed to --output-last-message so the CLI also
+	// writes the final agent message to disk.
+	LastMessageFile string
+
+	// Progress is called periodically with the elapsed time while the turn runs.
+	Progress func(elapsed time.Duration)
+
+	// BackpressureObserver is called when delivering an event to the consumer
+	// blocks for a noticeable time.
+	BackpressureObserver func(waitedFor time.Duration)
+
+	// IdleTimeout cancels the turn when no event arrives for this long.
+	// The timer resets on every event. Zero disables the idle timeout.
+	IdleTimeout time.Duration
+
+	// StripANSI removes escape sequences and control characters from agent
+	// message text as events are parsed.
+	StripANSI bool
+
+	// ImageMaxDimension downscales local images larger than this many pixels
+	// on either side before they are sent. Zero disables compression.
+	ImageMaxDimension int
+
+	// ImageQuality is the JPEG quality, from 1 to 100, of downscaled images.
+	ImageQuality int
+
+	// TurnTempFilesHook is called once the turn's process has exited with the
+	// per-turn temporary files the SDK created, after removing them.
+	TurnTempFilesHook func(paths []string)
+
+	// model replaces the thread's model when a turn is retried with a
+	// fallback model.
+	model string
 }
 
 // TurnOption is a functional option for configuring a Turn.
@@ -223,6 +967,115 @@ func WithOutputSchema(schema any) TurnOption {
 	}
 }
 
+// WithReasoningEffort overrides the thread's reasoning effort for a single
+// turn. Later turns fall back to the thread-level setting.
+func WithReasoningEffort(effort ModelReasoningEffort) TurnOption {
+	return func(o *TurnOptions) {
+		o.ModelReasoningEffort = effort
+	}
+}
+
+// WithContext prepends a block of context to the turn's prompt, separated
+// from the user prompt by a horizontal rule. It may be repeated; blocks keep
+// their order. This is plain prompt composition: the CLI receives a single
+// user message and no role metadata.
+func WithContext(text string) TurnOption {
+	return func(o *TurnOptions) {
+		if text != "" {
+			o.Context = append(o.Context, text)
+		}
+	}
+}
+
+// WithPromptCacheKey pins the prompt caching key for a turn so that related
+// turns share cached input tokens (reported in Usage.CachedInputTokens).
+// The key must not be empty.
+func WithPromptCacheKey(key string) TurnOption {
+	return func(o *TurnOptions) {
+		o.PromptCacheKey = &key
+	}
+}
+
+// WithLastMessageFile asks the CLI to write the final agent message to path
+// (--output-last-message). When the turn emits no agent_message item, Run
+// populates Turn.FinalResponse from the file instead.
+// No-op when path is empty.
+func WithLastMessageFile(path string) TurnOption {
+	return func(o *TurnOptions) {
+		if path != "" {
+			o.LastMessageFile = path
+		}
+	}
+}
+
+// WithProgress calls fn about once a second with the time elapsed since the
+// turn started, independently of events. It is useful for spinners while the
+// agent is silent, for example during a long-running command. fn is never
+// called after the turn completes.
+func WithProgress(fn func(elapsed time.Duration)) TurnOption {
+	return func(o *TurnOptions) {
+		o.Progress = fn
+	}
+}
+
+// WithBackpressureObserver calls fn from the streaming goroutine whenever an
+// event waits 100ms or longer for the consumer to receive it, with the time
+// it waited. Frequent calls mean the consumer, not the CLI, limits
+// throughput. While fn runs no further events are read, so it should return
+// quickly.
+func WithBackpressureObserver(fn func(waitedFor time.Duration)) TurnOption {
+	return func(o *TurnOptions) {
+		o.BackpressureObserver = fn
+	}
+}
+
+// WithIdleTimeout cancels the turn with ErrIdleTimeout if no event arrives
+// for d. Unlike a context deadline, a slow turn that keeps producing events
+// is never cancelled.
+func WithIdleTimeout(d time.Duration) TurnOption {
+	return func(o *TurnOptions) {
+		o.IdleTimeout = d
+	}
+}
+
+// WithStripANSI removes ANSI escape sequences and control characters other
+// than newlines, carriage returns and tabs from agent message text before it
+// reaches Events, MessageText or the Turn. Use it when rendering messages in
+// a terminal UI. Other item types are left untouched.
+func WithStripANSI() TurnOption {
+	return func(o *TurnOptions) {
+		o.StripANSI = true
+	}
+}
+
+// WithImageAutoCompress downscales local images wider or taller than maxDim
+// pixels to fit within maxDim, keeping their aspect ratio, and re-encodes them
+// as JPEG at quality (1-100, or 0 for a default of 85) to save tokens on
+// large screenshots. PNG, JPEG, and GIF images are supported; other files,
+// images that already fit and images over 50 megapixels, which are not
+// decoded to bound memory use, are sent unchanged. The downscaled copies are
+// written to a temporary directory and removed after the turn.
+func WithImageAutoCompress(maxDim, quality int) TurnOption {
+	return func(o *TurnOptions) {
+		o.ImageMaxDimension = maxDim
+		o.ImageQuality = quality
+	}
+}
+
+// WithTurnTempFilesHook calls fn after the turn with the paths of the
+// temporary files the SDK created for that turn alone: the output schema file
+// and images downscaled by WithImageAutoCompress. The files have already been
+// removed when fn runs; use it to audit temp placement, for example when the
+// default temp directory is on a read-only root. Files that outlive a turn,
+// such as the inline instructions file or a WithGitWorktree checkout, are
+// removed by Codex.Close and are not reported, and neither are files the CLI
+// creates itself. fn is not called if the turn fails before the CLI starts.
+func WithTurnTempFilesHook(fn func(paths []string)) TurnOption {
+	return func(o *TurnOptions) {
+		o.TurnTempFilesHook = fn
+	}
+}
+
 // applyCodexOptions applies functional options to CodexOptions.
 func applyCodexOptions(opts []Option) CodexOptions {
 	var options CodexOptions
@@ -1,11 +1,236 @@
 package codex
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/invopop/jsonschema"
 )
 
+// SchemaFromStruct reflects a JSON schema from a Go struct (or pointer to one)
+// in the shape the codex CLI expects: nested structs are inlined rather than
+// referenced, $schema and $id are removed, and every field without omitempty
+// is listed in required.
+//
+// Example:
+//
+//	type RepoStatus struct {
+//		Summary string `json:"summary"`
+//		Status  string `json:"status" jsonschema:"enum=ok,enum=action_required"`
+//	}
+//
+//	schema, err := codex.SchemaFromStruct(RepoStatus{})
+//	turn, err := thread.Run(ctx, codex.Text("Summarize repository status"),
+//		codex.WithOutputSchema(schema))
+func SchemaFromStruct(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, &ErrInvalidInput{
+			Field:  "output schema",
+			Reason: "struct value must not be nil",
+		}
+	}
+
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, &ErrInvalidInput{
+			Field:  "output schema",
+			Value:  typ.String(),
+			Reason: "must be a struct",
+		}
+	}
+
+	reflector := &jsonschema.Reflector{
+		DoNotReference: true,
+		ExpandedStruct: true,
+	}
+	data, err := json.Marshal(reflector.Reflect(v))
+	if err != nil {
+		return nil, fmt.Errorf("marshal reflected schema: %w", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("unmarshal reflected schema: %w", err)
+	}
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	return schema, nil
+}
+
+// RunStructured runs prompt on the thread with an output schema reflected
+// from T by SchemaFromStruct, and decodes the final response into a T. T must
+// be a struct type or a pointer to one. The schema replaces any set with
+// WithOutputSchema in opts. When the turn succeeds but its response does not
+// decode, the turn is returned together with the error.
+//
+// Example:
+//
+//	type RepoStatus struct {
+//		Summary string `json:"summary"`
+//		Status  string `json:"status" jsonschema:"enum=ok,enum=action_required"`
+//	}
+//
+//	status, turn, err := codex.RunStructured[RepoStatus](ctx, thread,
+//		"Summarize repository status")
+func RunStructured[T any](ctx context.Context, t *Thread, prompt string, opts ...TurnOption) (T, *Turn, error) {
+	var result T
+	schema, err := SchemaFromStruct(result)
+	if err != nil {
+		return result, nil, err
+	}
+
+	turnOpts := append(slices.Clip(opts), WithOutputSchema(schema))
+	turn, err := t.Run(ctx, Text(prompt), turnOpts...)
+	if err != nil {
+		return result, nil, err
+	}
+	if err := json.Unmarshal([]byte(turn.FinalResponse), &result); err != nil {
+		return result, turn, fmt.Errorf("decode structured output: %w", err)
+	}
+	return result, turn, nil
+}
+
+// NormalizeOutputSchema returns a copy of schema adjusted for strict
+// structured output: every object node, including those nested under
+// properties, items, $defs, and the anyOf, oneOf, and allOf combinators, gets
+// "additionalProperties": false and a required list naming all of its
+// properties. Properties already listed in required keep their order and the
+// rest are appended in sorted order. The input is not modified.
+func NormalizeOutputSchema(schema map[string]any) map[string]any {
+	if schema == nil {
+		return nil
+	}
+	return normalizeSchemaNode(schema)
+}
+
+func normalizeSchemaNode(node map[string]any) map[string]any {
+	out := make(map[string]any, len(node))
+	for key, value := range node {
+		switch key {
+		case "properties", "$defs", "definitions":
+			if children, ok := value.(map[string]any); ok {
+				normalized := make(map[string]any, len(children))
+				for name, child := range children {
+					normalized[name] = normalizeSchemaValue(child)
+				}
+				out[key] = normalized
+				continue
+			}
+		case "items", "anyOf", "oneOf", "allOf", "not":
+			out[key] = normalizeSchemaValue(value)
+			continue
+		}
+		out[key] = copySchemaValue(value)
+	}
+
+	if !isObjectSchema(out) {
+		return out
+	}
+	out["additionalProperties"] = false
+
+	properties, _ := out["properties"].(map[string]any)
+	var required []any
+	listed := make(map[string]bool)
+	switch existing := out["required"].(type) {
+	case []any:
+		for _, name := range existing {
+			if s, ok := name.(string); ok && !listed[s] {
+				listed[s] = true
+				required = append(required, s)
+			}
+		}
+	case []string:
+		for _, name := range existing {
+			if !listed[name] {
+				listed[name] = true
+				required = append(required, name)
+			}
+		}
+	}
+	var missing []string
+	for name := range properties {
+		if !listed[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		required = append(required, name)
+	}
+	if required == nil {
+		required = []any{}
+	}
+	out["required"] = required
+	return out
+}
+
+// normalizeSchemaValue normalizes a subschema or a list of subschemas.
+func normalizeSchemaValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return normalizeSchemaNode(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalizeSchemaValue(item)
+		}
+		return out
+	default:
+		return copySchemaValue(value)
+	}
+}
+
+// isObjectSchema reports whether node describes a JSON object.
+func isObjectSchema(node map[string]any) bool {
+	if _, ok := node["properties"]; ok {
+		return true
+	}
+	switch typ := node["type"].(type) {
+	case string:
+		return typ == "object"
+	case []any:
+		for _, t := range typ {
+			if t == "object" {
+				return true
+			}
+		}
+	case []string:
+		return slices.Contains(typ, "object")
+	}
+	return false
+}
+
+// copySchemaValue deep-copies the maps and slices of a decoded JSON value.
+func copySchemaValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = copySchemaValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = copySchemaValue(item)
+		}
+		return out
+	case []string:
+		return slices.Clone(v)
+	default:
+		return value
+	}
+}
+
 // outputSchemaFile manages a temporary file containing the output schema.
 type outputSchemaFile struct {
 	path    string
@@ -0,0 +1,244 @@
+package codex
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaFromStruct(t *testing.T) {
+	type details struct {
+		Files int `json:"files"`
+	}
+	type repoStatus struct {
+		Summary string  `json:"summary"`
+		Status  string  `json:"status" jsonschema:"enum=ok,enum=action_required"`
+		Details details `json:"details"`
+		Note    string  `json:"note,omitempty"`
+	}
+
+	schema, err := SchemaFromStruct(&repoStatus{})
+	if err != nil {
+		t.Fatalf("SchemaFromStruct failed: %v", err)
+	}
+
+	want := map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"summary": map[string]any{"type": "string"},
+			"status":  map[string]any{"type": "string", "enum": []any{"ok", "action_required"}},
+			"details": map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"files": map[string]any{"type": "integer"},
+				},
+				"required": []any{"files"},
+			},
+			"note": map[string]any{"type": "string"},
+		},
+		"required": []any{"summary", "status", "details"},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("unexpected schema:\n got: %#v\nwant: %#v", schema, want)
+	}
+
+	if err := validateOutputSchema(schema); err != nil {
+		t.Errorf("generated schema should pass validation: %v", err)
+	}
+}
+
+func TestSchemaFromStructInvalid(t *testing.T) {
+	var invalidInput *ErrInvalidInput
+	if _, err := SchemaFromStruct(nil); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for nil, got %v", err)
+	}
+	if _, err := SchemaFromStruct("not a struct"); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for string, got %v", err)
+	}
+}
+
+func TestNormalizeOutputSchema(t *testing.T) {
+	input := map[string]any{
+		"type":     "object",
+		"required": []any{"tags"},
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"tags": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+			"owner": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"login": map[string]any{"type": "string"},
+					"id":    map[string]any{"type": "integer"},
+				},
+				"additionalProperties": true,
+			},
+			"files": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string"},
+					},
+				},
+			},
+			"meta": map[string]any{
+				"anyOf": []any{
+					map[string]any{"type": []any{"object", "null"}},
+					map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+
+	want := map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []any{"tags", "files", "meta", "name", "owner"},
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"tags": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+			"owner": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"login": map[string]any{"type": "string"},
+					"id":    map[string]any{"type": "integer"},
+				},
+				"additionalProperties": false,
+				"required":             []any{"id", "login"},
+			},
+			"files": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string"},
+					},
+					"additionalProperties": false,
+					"required":             []any{"path"},
+				},
+			},
+			"meta": map[string]any{
+				"anyOf": []any{
+					map[string]any{
+						"type":                 []any{"object", "null"},
+						"additionalProperties": false,
+						"required":             []any{},
+					},
+					map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+
+	got := NormalizeOutputSchema(input)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected normalized schema:\n got: %#v\nwant: %#v", got, want)
+	}
+
+	owner := input["properties"].(map[string]any)["owner"].(map[string]any)
+	if owner["additionalProperties"] != true {
+		t.Errorf("expected input to be left unmodified, got owner %v", owner)
+	}
+	if _, ok := input["additionalProperties"]; ok {
+		t.Errorf("expected no additionalProperties added to the input, got %v", input)
+	}
+	if reqs := input["required"].([]any); len(reqs) != 1 {
+		t.Errorf("expected input required list unchanged, got %v", reqs)
+	}
+
+	if NormalizeOutputSchema(nil) != nil {
+		t.Error("expected nil for a nil schema")
+	}
+}
+
+func TestRunStructured(t *testing.T) {
+	type repoStatus struct {
+		Summary string `json:"summary"`
+		Status  string `json:"status" jsonschema:"enum=ok,enum=action_required"`
+	}
+
+	schemaCopy := filepath.Join(t.TempDir(), "schema.json")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--output-schema" ]; then cp "$2" '`+schemaCopy+`'; fi
+  shift
+done
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"{\"summary\":\"all good\",\"status\":\"ok\"}"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	status, turn, err := RunStructured[repoStatus](testContext(t), thread, "Summarize repository status")
+	if err != nil {
+		t.Fatalf("RunStructured failed: %v", err)
+	}
+	if want := (repoStatus{Summary: "all good", Status: "ok"}); status != want {
+		t.Errorf("expected %+v, got %+v", want, status)
+	}
+	if turn == nil || turn.FinalResponse == "" {
+		t.Errorf("expected the turn to be returned, got %+v", turn)
+	}
+
+	data, err := os.ReadFile(schemaCopy)
+	if err != nil {
+		t.Fatalf("expected the schema to be passed to the CLI: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema file: %v", err)
+	}
+	want, err := SchemaFromStruct(repoStatus{})
+	if err != nil {
+		t.Fatalf("SchemaFromStruct failed: %v", err)
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("expected schema reflected from the type\n got: %v\nwant: %v", schema, want)
+	}
+
+	ptr, _, err := RunStructured[*repoStatus](testContext(t), thread, "Summarize repository status")
+	if err != nil {
+		t.Fatalf("RunStructured with a pointer type failed: %v", err)
+	}
+	if ptr == nil || ptr.Status != "ok" {
+		t.Errorf("expected decoded pointer, got %+v", ptr)
+	}
+}
+
+func TestRunStructuredErrors(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"not json"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	type result struct {
+		Answer string `json:"answer"`
+	}
+	_, turn, err := RunStructured[result](testContext(t), thread, "hello")
+	if err == nil || !strings.Contains(err.Error(), "decode structured output") {
+		t.Errorf("expected decode error, got %v", err)
+	}
+	if turn == nil || turn.FinalResponse != "not json" {
+		t.Errorf("expected the turn alongside a decode error, got %+v", turn)
+	}
+
+	_, _, err = RunStructured[string](testContext(t), thread, "hello")
+	var invalid *ErrInvalidInput
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected *ErrInvalidInput for a non-struct type, got %v", err)
+	}
+}
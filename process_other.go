@@ -0,0 +1,24 @@
+//go:build !unix
+
+package codex
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without Unix process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate asks the process started by cmd to exit with os.Interrupt,
+// falling back to killing it where interrupts cannot be sent, as on Windows.
+// A process that ignores the interrupt is killed by exec once cmd.WaitDelay
+// expires.
+func terminate(cmd *exec.Cmd, group bool) error {
+	err := cmd.Process.Signal(os.Interrupt)
+	if err == nil || errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return cmd.Process.Kill()
+}
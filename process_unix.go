@@ -0,0 +1,36 @@
+//go:build unix
+
+package codex
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in a new process group, so that terminate can
+// signal the CLI and every process it started.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate asks the process started by cmd to exit by sending it SIGTERM,
+// or its whole process group when group is set. A process that ignores the
+// signal is killed by exec once cmd.WaitDelay expires; the group is killed
+// after the same delay, so descendants cannot outlive a stubborn CLI.
+func terminate(cmd *exec.Cmd, group bool) error {
+	pid := cmd.Process.Pid
+	if group {
+		pid = -pid
+		time.AfterFunc(cmd.WaitDelay, func() {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		})
+	}
+	err := syscall.Kill(pid, syscall.SIGTERM)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package codex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWithProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+sleep 30 &
+echo $! > '`+pidFile+`'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+wait
+`)
+	client, err := New(WithCodexPath(script), WithProcessGroup())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(testContext(t))
+	defer cancel()
+	streamed, err := client.StartThread().RunStreamed(ctx, Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	if _, ok := streamed.Next(ctx, func(e ThreadEvent) bool { return e.Type == EventThreadStarted }); !ok {
+		t.Fatal("stream ended before thread.started")
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("failed to read child pid: %v", err)
+	}
+	child, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("invalid child pid %q: %v", data, err)
+	}
+	if pgid, err := syscall.Getpgid(child); err != nil || pgid != streamed.PID() {
+		t.Fatalf("expected child in process group %d, got %d (%v)", streamed.PID(), pgid, err)
+	}
+
+	start := time.Now()
+	cancel()
+	for range streamed.Events {
+	}
+	_ = streamed.Wait()
+	// Without the group kill, the orphaned sleep holds stderr open until
+	// stderrWaitDelay expires.
+	if elapsed := time.Since(start); elapsed >= stderrWaitDelay/2 {
+		t.Errorf("expected the process group to be killed promptly, took %s", elapsed)
+	}
+}
@@ -0,0 +1,51 @@
+package codex
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// rateLimitPattern recognizes rate-limit failures in CLI stderr. A 429
+	// only counts next to a word such as "status", so that unrelated numbers
+	// in paths or counts do not match.
+	rateLimitPattern = regexp.MustCompile(`(?i)rate[ _-]?limit|too many requests|(?:status|http|code)[^\n]{0,10}\b429\b`)
+	// retryAfterPattern extracts a suggested delay such as "Retry-After: 30"
+	// or "try again in 1.5s".
+	retryAfterPattern = regexp.MustCompile(`(?i)(?:retry[ -]after:?|try again in)\s*(\d+(?:\.\d+)?)\s*(ms|milliseconds?|s|secs?|seconds?|m|mins?|minutes?)?\b`)
+)
+
+// rateLimitError wraps err in an *ErrRateLimited when stderr reports a rate
+// limit, and returns err unchanged otherwise. Detection is heuristic: stderr
+// must mention a rate limit explicitly, and RetryAfter is only set when a
+// delay can be parsed.
+func rateLimitError(stderr string, err error) error {
+	if !rateLimitPattern.MatchString(stderr) {
+		return err
+	}
+	return &ErrRateLimited{RetryAfter: parseRetryAfter(stderr), Err: err}
+}
+
+// parseRetryAfter returns the first retry delay found in s, or zero. A bare
+// number is read as seconds, as in the Retry-After HTTP header.
+func parseRetryAfter(s string) time.Duration {
+	m := retryAfterPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	unit := time.Second
+	switch strings.ToLower(m[2]) {
+	case "ms", "millisecond", "milliseconds":
+		unit = time.Millisecond
+	case "m", "min", "mins", "minute", "minutes":
+		unit = time.Minute
+	}
+	return time.Duration(value * float64(unit))
+}
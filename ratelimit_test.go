@@ -0,0 +1,100 @@
+package codex
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"Retry-After: 30", 30 * time.Second},
+		{"stream error: 429 Too Many Requests; retry after 12s", 12 * time.Second},
+		{"Rate limit reached. Please try again in 1.5s.", 1500 * time.Millisecond},
+		{"Please try again in 250ms", 250 * time.Millisecond},
+		{"try again in 2 minutes", 2 * time.Minute},
+		{"rate limit exceeded", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	execErr := &ErrExecFailed{ExitCode: 1, Stderr: "boom"}
+
+	if err := rateLimitError("unexpected status 500: try again in 5s", execErr); err != execErr {
+		t.Errorf("expected non rate-limit failure to be returned unchanged, got %v", err)
+	}
+	if err := rateLimitError("patch failed: wrote 429 lines to report-429.txt", execErr); err != execErr {
+		t.Errorf("expected an unrelated 429 to be ignored, got %v", err)
+	}
+	if err := rateLimitError("unexpected status code: 429", execErr); !errors.As(err, new(*ErrRateLimited)) {
+		t.Errorf("expected a 429 status to be rate limited, got %v", err)
+	}
+
+	err := rateLimitError("exceeded retry limit, last status: 429 Too Many Requests", execErr)
+	var rateLimited *ErrRateLimited
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if rateLimited.RetryAfter != 0 {
+		t.Errorf("expected no retry delay, got %s", rateLimited.RetryAfter)
+	}
+	var unwrapped *ErrExecFailed
+	if !errors.As(err, &unwrapped) || unwrapped != execErr {
+		t.Errorf("expected ErrRateLimited to wrap the exec failure, got %v", err)
+	}
+}
+
+func TestExecRateLimited(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stderr script is not supported on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex-rate-limit.sh")
+	script := `#!/bin/sh
+cat > /dev/null
+echo 'ERROR: Rate limit reached for gpt-5. Please try again in 20s.' >&2
+exit 1
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+
+	exec, err := newExec(scriptPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create exec: %v", err)
+	}
+	stream, err := exec.Run(context.Background(), ExecArgs{Input: "test input"})
+	if err != nil {
+		t.Fatalf("failed to start exec: %v", err)
+	}
+	defer stream.Close()
+	scanner := bufio.NewScanner(stream.Stdout())
+	for scanner.Scan() {
+	}
+
+	err = stream.Wait()
+	var rateLimited *ErrRateLimited
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if rateLimited.RetryAfter != 20*time.Second {
+		t.Errorf("expected retry after 20s, got %s", rateLimited.RetryAfter)
+	}
+	var execErr *ErrExecFailed
+	if !errors.As(err, &execErr) || execErr.ExitCode != 1 {
+		t.Errorf("expected wrapped ErrExecFailed with exit code 1, got %v", err)
+	}
+}
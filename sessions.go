@@ -0,0 +1,103 @@
+package codex
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// sessionFilePattern matches persisted session files such as
+// rollout-2025-01-02T03-04-05-<thread id>.jsonl and captures the thread ID.
+var sessionFilePattern = regexp.MustCompile(`^rollout-.*-([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})\.jsonl$`)
+
+// sessionsDir returns the directory the CLI persists sessions in:
+// $CODEX_HOME/sessions, or ~/.codex/sessions when CODEX_HOME is unset, where
+// ~ is the WithHomeDir directory if set. CODEX_HOME is read from WithEnv when
+// set, since that replaces the CLI's environment.
+func (c *Codex) sessionsDir() (string, error) {
+	var home string
+	if c.options.Env != nil {
+		home = c.options.Env["CODEX_HOME"]
+	} else {
+		home = os.Getenv("CODEX_HOME")
+	}
+	if home == "" {
+		userHome := c.options.HomeDir
+		if userHome == "" {
+			var err error
+			if userHome, err = os.UserHomeDir(); err != nil {
+				return "", err
+			}
+		}
+		home = filepath.Join(userHome, ".codex")
+	}
+	return filepath.Join(home, "sessions"), nil
+}
+
+// ThreadInfo describes a session persisted by the CLI.
+type ThreadInfo struct {
+	// ID is the thread ID, which can be passed to ResumeThread.
+	ID string
+	// Path is the session file.
+	Path string
+	// ModTime is when the session file was last written.
+	ModTime time.Time
+}
+
+// ListThreads returns the sessions persisted by the CLI, most recently
+// modified first. A missing sessions directory yields no sessions.
+func (c *Codex) ListThreads() ([]ThreadInfo, error) {
+	dir, err := c.sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var threads []ThreadInfo
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		m := sessionFilePattern.FindStringSubmatch(d.Name())
+		if m == nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		threads = append(threads, ThreadInfo{ID: m[1], Path: path, ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan codex sessions: %w", err)
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].ModTime.After(threads[j].ModTime)
+	})
+	return threads, nil
+}
+
+// ResumeLatest resumes the most recently modified session persisted by the
+// CLI, the first one ListThreads returns. It returns ErrNoSessions if there
+// is none.
+func (c *Codex) ResumeLatest(opts ...ThreadOption) (*Thread, error) {
+	threads, err := c.ListThreads()
+	if err != nil {
+		return nil, err
+	}
+	if len(threads) == 0 {
+		return nil, ErrNoSessions
+	}
+	return c.ResumeThread(threads[0].ID, opts...), nil
+}
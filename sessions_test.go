@@ -0,0 +1,119 @@
+package codex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSessionFixture(t *testing.T, home, name string, modTime time.Time) {
+	t.Helper()
+	dir := filepath.Join(home, "sessions", modTime.Format("2006/01/02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("failed to write session fixture: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to set session mtime: %v", err)
+	}
+}
+
+func TestResumeLatest(t *testing.T) {
+	home := t.TempDir()
+	now := time.Now()
+	writeSessionFixture(t, home, "rollout-2025-01-01T10-00-00-0199a213-81c0-7800-8aa1-bbab2a035a53.jsonl", now.Add(-2*time.Hour))
+	writeSessionFixture(t, home, "rollout-2025-01-02T10-00-00-0199a213-81c0-7800-8aa1-000000000002.jsonl", now.Add(-time.Minute))
+	writeSessionFixture(t, home, "rollout-2025-01-03T10-00-00-0199a213-81c0-7800-8aa1-000000000003.jsonl", now.Add(-time.Hour))
+	writeSessionFixture(t, home, "notes.txt", now)
+
+	client, err := New(WithCodexPath("/bin/true"), WithEnv(map[string]string{"CODEX_HOME": home}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	thread, err := client.ResumeLatest(WithModel("gpt-5"))
+	if err != nil {
+		t.Fatalf("ResumeLatest failed: %v", err)
+	}
+	if want := "0199a213-81c0-7800-8aa1-000000000002"; thread.ID() != want {
+		t.Errorf("expected latest thread %q, got %q", want, thread.ID())
+	}
+	if thread.threadOptions.Model != "gpt-5" {
+		t.Errorf("expected thread options to apply, got model %q", thread.threadOptions.Model)
+	}
+}
+
+func TestListThreads(t *testing.T) {
+	home := t.TempDir()
+	now := time.Now()
+	writeSessionFixture(t, home, "rollout-2025-01-01T10-00-00-0199a213-81c0-7800-8aa1-bbab2a035a53.jsonl", now.Add(-2*time.Hour))
+	writeSessionFixture(t, home, "rollout-2025-01-02T10-00-00-0199a213-81c0-7800-8aa1-000000000002.jsonl", now.Add(-time.Minute))
+	writeSessionFixture(t, home, "notes.txt", now)
+
+	client, err := New(WithCodexPath("/bin/true"), WithEnv(map[string]string{"CODEX_HOME": home}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	threads, err := client.ListThreads()
+	if err != nil {
+		t.Fatalf("ListThreads failed: %v", err)
+	}
+	want := []string{"0199a213-81c0-7800-8aa1-000000000002", "0199a213-81c0-7800-8aa1-bbab2a035a53"}
+	if len(threads) != len(want) {
+		t.Fatalf("expected %d threads, got %+v", len(want), threads)
+	}
+	for i, id := range want {
+		if threads[i].ID != id {
+			t.Errorf("thread %d: expected %q, got %q", i, id, threads[i].ID)
+		}
+		if !strings.HasSuffix(threads[i].Path, id+".jsonl") {
+			t.Errorf("thread %d: unexpected path %q", i, threads[i].Path)
+		}
+	}
+}
+
+func TestResumeLatestNoSessions(t *testing.T) {
+	for _, name := range []string{"empty", "missing"} {
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			if name == "empty" {
+				if err := os.MkdirAll(filepath.Join(home, "sessions"), 0o755); err != nil {
+					t.Fatalf("failed to create sessions dir: %v", err)
+				}
+			}
+			client, err := New(WithCodexPath("/bin/true"), WithEnv(map[string]string{"CODEX_HOME": home}))
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			if _, err := client.ResumeLatest(); !errors.Is(err, ErrNoSessions) {
+				t.Errorf("expected ErrNoSessions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResumeLatestHomeDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CODEX_HOME", "")
+	writeSessionFixture(t, filepath.Join(home, ".codex"), "rollout-2025-01-01T10-00-00-0199a213-81c0-7800-8aa1-bbab2a035a53.jsonl", time.Now())
+
+	client, err := New(WithCodexPath("/bin/true"), WithHomeDir(home))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	thread, err := client.ResumeLatest()
+	if err != nil {
+		t.Fatalf("ResumeLatest failed: %v", err)
+	}
+	if want := "0199a213-81c0-7800-8aa1-bbab2a035a53"; thread.ID() != want {
+		t.Errorf("expected thread %q from the home dir, got %q", want, thread.ID())
+	}
+}
@@ -1,16 +1,25 @@
 package codex
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"slices"
 	"sync"
+	"time"
 )
 
+// progressInterval is how often WithProgress callbacks fire.
+var progressInterval = time.Second
+
+// backpressureThreshold is how long an event send must block before the
+// WithBackpressureObserver callback fires.
+var backpressureThreshold = 100 * time.Millisecond
+
 // Thread represents a conversation with the Codex agent.
 // One thread can have multiple consecutive turns.
 type Thread struct {
@@ -19,6 +28,20 @@ type Thread struct {
 	threadOptions ThreadOptions
 	id            string
 	mu            sync.RWMutex
+	turns         *activeTurns
+	tempDirs      *tempDirs
+	slots         semaphore
+	// instructionsPath caches the file written for ThreadOptions.Instructions.
+	instructionsPath string
+	// worktreeDir caches the working directory inside the thread's git
+	// worktree.
+	worktreeDir string
+	// worktreePending is closed once a turn creating the worktree finishes.
+	worktreePending chan struct{}
+	// history holds the items completed across all turns, guarded by mu.
+	history []ThreadItem
+	// eventLog receives the raw events of every turn, when configured.
+	eventLog *eventLog
 }
 
 // ID returns the identifier of the thread.
@@ -38,6 +61,47 @@ func (t *Thread) setID(id string) {
 	t.mu.Unlock()
 }
 
+// adoptID records the ID reported by a thread.started event. A thread that
+// already has a different ID keeps it: the mismatch is logged, or returned as
+// ErrThreadIDMismatch when StrictThreadID is set.
+func (t *Thread) adoptID(id string) error {
+	if id == "" {
+		return nil
+	}
+	t.mu.Lock()
+	current := t.id
+	if current == "" || current == id {
+		t.id = id
+		t.mu.Unlock()
+		return nil
+	}
+	t.mu.Unlock()
+
+	if t.threadOptions.StrictThreadID {
+		return fmt.Errorf("%w: thread %s reported as %s", ErrThreadIDMismatch, current, id)
+	}
+	if logger := t.codexOptions.Logger; logger != nil {
+		logger.Warn("codex reported a different thread ID; keeping the original",
+			"thread_id", current, "reported_thread_id", id)
+	}
+	return nil
+}
+
+// History returns every item completed on the thread across all turns run
+// through this Thread value, in order, including turns that failed or were
+// interrupted. Items from sessions resumed by ID are not loaded.
+func (t *Thread) History() []ThreadItem {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]ThreadItem(nil), t.history...)
+}
+
+func (t *Thread) recordHistory(item ThreadItem) {
+	t.mu.Lock()
+	t.history = append(t.history, item)
+	t.mu.Unlock()
+}
+
 func (t *Thread) currentID() string {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -52,6 +116,24 @@ type Turn struct {
 	FinalResponse string
 	// Usage reports token consumption for the turn.
 	Usage *Usage
+	// StartedAt is when the turn.started event was observed.
+	StartedAt time.Time
+	// CompletedAt is when the turn.completed event was observed.
+	CompletedAt time.Time
+	// Interrupted reports that the turn was stopped by
+	// StreamedTurn.Interrupt, so Items holds only what completed before then.
+	Interrupted bool
+
+	todoHistory [][]TodoItem
+}
+
+// Duration returns the time between the turn.started and turn.completed
+// events, or zero if either was not observed.
+func (t *Turn) Duration() time.Duration {
+	if t.StartedAt.IsZero() || t.CompletedAt.IsZero() {
+		return 0
+	}
+	return t.CompletedAt.Sub(t.StartedAt)
 }
 
 // RunResult is an alias for Turn, matching the TypeScript SDK API.
@@ -61,14 +143,31 @@ type RunResult = Turn
 type StreamedTurn struct {
 	// Events yields parsed events in the order emitted by the CLI.
 	Events   <-chan ThreadEvent
+	pid      int
 	waitFn   func() error
 	waitOnce sync.Once
 	waitErr  error
+	cancel   context.CancelCauseFunc
+	// done is the Done channel of the context the turn was started with.
+	done <-chan struct{}
+	// interrupted is closed by Interrupt.
+	interrupted   chan struct{}
+	interruptOnce sync.Once
+	// partial aggregates every event read from the CLI. It is written by the
+	// streaming goroutine and may only be read once waitFn has returned.
+	partial  *turnBuilder
+	messages *messageAccumulator
 }
 
 // RunStreamedResult is an alias for StreamedTurn, matching the TypeScript SDK API.
 type RunStreamedResult = StreamedTurn
 
+// PID returns the process ID of the codex process backing this turn, or 0 if
+// no process was started. The process may already have exited.
+func (s *StreamedTurn) PID() int {
+	return s.pid
+}
+
 // Wait blocks until the underlying run completes and returns any terminal error.
 // This method is safe to call concurrently from multiple goroutines.
 // Subsequent calls will return the same error as the first call.
@@ -83,37 +182,207 @@ func (s *StreamedTurn) Wait() error {
 	return s.waitErr
 }
 
+// Interrupt stops the turn and returns the items completed so far, with
+// Interrupted set. Items include events dropped by WithEventFilter; events
+// not yet read from Events are discarded. If the turn finished before it
+// could be interrupted, the complete turn is returned with Interrupted unset.
+// Other failures of the turn are returned as errors.
+//
+// After Interrupt, Wait reports ErrInterrupted.
+func (s *StreamedTurn) Interrupt() (*Turn, error) {
+	if s.cancel != nil {
+		s.cancel(ErrInterrupted)
+	}
+	s.interruptOnce.Do(func() {
+		if s.interrupted != nil {
+			close(s.interrupted)
+		}
+	})
+	for range s.Events {
+	}
+
+	err := s.Wait()
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		return nil, err
+	}
+	turn := s.partial.turn
+	turn.Interrupted = err != nil
+	return &turn, nil
+}
+
+// MessageText returns the text of the agent message with the given item ID,
+// assembled from the deltas read from the CLI so far. It may include the
+// event about to be delivered on Events. MessageText is safe to call while
+// Events is being read.
+func (s *StreamedTurn) MessageText(id string) string {
+	return s.messages.text(id)
+}
+
+// FileChanges reads Events and delivers the individual file operations of
+// each file_change item as soon as the item completes, with their diffs when
+// the CLI reports them. Patches that failed to apply are skipped. The
+// returned channel is closed once Events is closed.
+//
+// FileChanges consumes Events: other events are discarded, so Events must not
+// be read elsewhere. Drain the returned channel, then call Wait as usual. To
+// stop reading early, cancel the context the turn was started with or call
+// Interrupt; either also closes the returned channel.
+func (s *StreamedTurn) FileChanges() <-chan FileUpdateChange {
+	changes := make(chan FileUpdateChange)
+	go func() {
+		defer close(changes)
+		for event := range s.Events {
+			item, ok := event.Item.(*FileChangeItem)
+			if !ok || event.Type != EventItemCompleted || item.Status == PatchFailed {
+				continue
+			}
+			for _, change := range item.Changes {
+				select {
+				case changes <- change:
+				case <-s.done:
+					return
+				case <-s.interrupted:
+					return
+				}
+			}
+		}
+	}()
+	return changes
+}
+
+// Next receives events until pred returns true for one and returns it. It
+// returns false when Events is closed or ctx is done first.
+//
+// Next consumes the events it skips: they are not delivered to later calls or
+// to other readers of Events. Call Wait after the stream is drained as usual.
+func (s *StreamedTurn) Next(ctx context.Context, pred func(ThreadEvent) bool) (ThreadEvent, bool) {
+	for {
+		select {
+		case event, ok := <-s.Events:
+			if !ok {
+				return ThreadEvent{}, false
+			}
+			if pred(event) {
+				return event, true
+			}
+		case <-ctx.Done():
+			return ThreadEvent{}, false
+		}
+	}
+}
+
 // Run executes a complete agent turn with the provided input and returns its result.
 // The call blocks until the CLI exits or the context is cancelled.
 func (t *Thread) Run(ctx context.Context, input Input, opts ...TurnOption) (*Turn, error) {
+	return t.run(ctx, input, opts, nil)
+}
+
+// run executes a turn and aggregates its events into a Turn. When onEvent is
+// set it is called for every event; a non-nil error aborts the turn. With
+// AutoResumeFallback, a turn failing because the resumed session is missing
+// is retried once as a new thread.
+func (t *Thread) run(ctx context.Context, input Input, opts []TurnOption, onEvent func(ThreadEvent) error) (*Turn, error) {
+	resumedID := t.currentID()
+	turn, err := t.runWithModelFallback(ctx, input, opts, onEvent)
+	if err == nil || resumedID == "" || !t.threadOptions.AutoResumeFallback ||
+		t.threadOptions.SessionID != "" || !errors.Is(err, ErrSessionNotFound) {
+		return turn, err
+	}
+
+	if logger := t.codexOptions.Logger; logger != nil {
+		logger.Warn("codex session not found; starting a new thread",
+			"thread_id", resumedID, "error", err)
+	}
+	t.mu.Lock()
+	if t.id == resumedID {
+		t.id = ""
+	}
+	t.mu.Unlock()
+	return t.runWithModelFallback(ctx, input, opts, onEvent)
+}
+
+// runWithModelFallback runs a turn and, while it fails with
+// ErrModelUnavailable before running any command, changing any file or
+// calling any MCP tool, retries it with each of the thread's fallback models
+// in order. A retry continues the thread the failed attempt started from.
+func (t *Thread) runWithModelFallback(ctx context.Context, input Input, opts []TurnOption, onEvent func(ThreadEvent) error) (*Turn, error) {
+	if len(t.threadOptions.ModelFallback) == 0 {
+		return t.runTurn(ctx, input, opts, onEvent)
+	}
+
+	var sideEffects bool
+	track := func(event ThreadEvent) error {
+		switch event.Item.(type) {
+		case *CommandExecutionItem, *FileChangeItem, *McpToolCallItem:
+			sideEffects = true
+		}
+		if onEvent != nil {
+			return onEvent(event)
+		}
+		return nil
+	}
+
+	startID := t.currentID()
+	turn, err := t.runTurn(ctx, input, opts, track)
+	for _, model := range t.threadOptions.ModelFallback {
+		if err == nil || sideEffects || !errors.Is(err, ErrModelUnavailable) {
+			break
+		}
+		if logger := t.codexOptions.Logger; logger != nil {
+			logger.Warn("codex model unavailable; falling back",
+				"model", model, "error", err)
+		}
+		t.mu.Lock()
+		t.id = startID
+		t.mu.Unlock()
+
+		retryOpts := append(slices.Clip(opts), func(o *TurnOptions) { o.model = model })
+		turn, err = t.runTurn(ctx, input, retryOpts, track)
+	}
+	return turn, err
+}
+
+// turnFailureError converts the error of a turn.failed event into the error
+// returned by Run.
+func turnFailureError(failure *ThreadError) error {
+	err := errors.New(failure.Message)
+	if failure.Code == "context_length_exceeded" || contextLengthPattern.MatchString(failure.Message) {
+		return fmt.Errorf("%w: %w", ErrContextLengthExceeded, err)
+	}
+	if failure.Code == "model_not_found" || modelUnavailablePattern.MatchString(failure.Message) {
+		return fmt.Errorf("%w: %w", ErrModelUnavailable, err)
+	}
+	return err
+}
+
+// runTurn runs a single turn for run.
+func (t *Thread) runTurn(ctx context.Context, input Input, opts []TurnOption, onEvent func(ThreadEvent) error) (*Turn, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	streamed, err := t.runStreamedInternal(ctx, input, opts)
+	// The client's event filter only applies to streams handed to callers;
+	// onEvent sees every event.
+	streamed, err := t.runStreamedInternal(ctx, input, nil, nil, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	var (
-		items         []ThreadItem
-		finalResponse string
-		usage         *Usage
-		turnFailure   *ThreadError
+		turnFailure *ThreadError
+		handlerErr  error
 	)
 
 loop:
 	for event := range streamed.Events {
-		switch event.Type {
-		case EventItemCompleted:
-			if event.Item != nil {
-				if msg, ok := event.Item.(*AgentMessageItem); ok {
-					finalResponse = msg.Text
-				}
-				items = append(items, event.Item)
+		if onEvent != nil {
+			if err := onEvent(event); err != nil {
+				handlerErr = err
+				cancel()
+				break loop
 			}
-		case EventTurnCompleted:
-			usage = event.Usage
-		case EventTurnFailed:
+		}
+
+		if event.Type == EventTurnFailed {
 			if event.Error != nil {
 				turnFailure = event.Error
 			} else {
@@ -124,134 +393,589 @@ loop:
 		}
 	}
 
+	// Drain events left after an early break so the streaming goroutine
+	// never blocks on a send and always finishes its cleanup.
+	for range streamed.Events {
+	}
+
 	waitErr := streamed.Wait()
 
+	if handlerErr != nil {
+		return nil, handlerErr
+	}
+
 	if turnFailure != nil {
 		if waitErr != nil && !errors.Is(waitErr, context.Canceled) {
 			return nil, waitErr
 		}
-		return nil, errors.New(turnFailure.Message)
+		return nil, turnFailureError(turnFailure)
 	}
 
 	if waitErr != nil {
 		return nil, waitErr
 	}
 
-	return &Turn{Items: items, FinalResponse: finalResponse, Usage: usage}, nil
+	turn := streamed.partial.turn
+	if turn.FinalResponse == "" {
+		if path := t.turnOptions(opts).LastMessageFile; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("read last message file: %w", err)
+			}
+			turn.FinalResponse = string(data)
+		}
+	}
+	return &turn, nil
+}
+
+// RunString runs a text prompt and returns only the final agent response.
+// It is shorthand for Run with Text(prompt) followed by reading FinalResponse.
+func (t *Thread) RunString(ctx context.Context, prompt string, opts ...TurnOption) (string, error) {
+	turn, err := t.Run(ctx, Text(prompt), opts...)
+	if err != nil {
+		return "", err
+	}
+	return turn.FinalResponse, nil
+}
+
+// RunBatch runs each input as its own turn on the thread, one after another,
+// so later prompts see the conversation built by earlier ones. It stops at
+// the first failing turn and returns the turns that completed before it
+// together with the error, which names the index of the failed input.
+func (t *Thread) RunBatch(ctx context.Context, inputs []Input, opts ...TurnOption) ([]*Turn, error) {
+	turns := make([]*Turn, 0, len(inputs))
+	for i, input := range inputs {
+		turn, err := t.Run(ctx, input, opts...)
+		if err != nil {
+			return turns, fmt.Errorf("batch input %d: %w", i, err)
+		}
+		turns = append(turns, turn)
+	}
+	return turns, nil
+}
+
+// RunTo runs a turn like Run while streaming agent message text to w as it
+// arrives. Text from item.updated events is written incrementally and each
+// chunk is flushed when w implements Flush. Consecutive messages are separated
+// by a newline.
+func (t *Thread) RunTo(ctx context.Context, input Input, w io.Writer, opts ...TurnOption) (*Turn, error) {
+	mw := &messageWriter{w: w, written: make(map[string]int)}
+	return t.run(ctx, input, opts, mw.handle)
+}
+
+// messageWriter writes agent message text to an io.Writer incrementally.
+type messageWriter struct {
+	w       io.Writer
+	written map[string]int
+	lastID  string
+}
+
+func (m *messageWriter) handle(event ThreadEvent) error {
+	if event.Type != EventItemUpdated && event.Type != EventItemCompleted {
+		return nil
+	}
+	msg, ok := event.Item.(*AgentMessageItem)
+	if !ok {
+		return nil
+	}
+
+	offset := m.written[msg.ID]
+	if offset > len(msg.Text) {
+		// The message was rewritten rather than extended; nothing sensible
+		// can be appended.
+		return nil
+	}
+	chunk := msg.Text[offset:]
+	if chunk == "" {
+		return nil
+	}
+	if m.lastID != "" && m.lastID != msg.ID && offset == 0 {
+		chunk = "\n" + chunk
+	}
+
+	if _, err := io.WriteString(m.w, chunk); err != nil {
+		return fmt.Errorf("write agent message: %w", err)
+	}
+	m.written[msg.ID] = len(msg.Text)
+	m.lastID = msg.ID
+
+	switch f := m.w.(type) {
+	case interface{ Flush() error }:
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("flush agent message: %w", err)
+		}
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
+
+// startProgress calls fn on every progressInterval tick until the returned
+// stop function is called. stop waits for any in-flight callback to return.
+func startProgress(fn func(elapsed time.Duration)) (stop func()) {
+	start := time.Now()
+	ticker := time.NewTicker(progressInterval)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-ticker.C:
+				fn(time.Since(start))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-exited
+	}
+}
+
+// readErrRecorder remembers the first non-EOF error returned by r, so that
+// I/O failures can be told apart from malformed output once a decoder
+// reports an error.
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// startSpan starts a span through the client's WithStartSpan hook. Without a
+// hook it returns ctx and a finalizer that does nothing.
+func (t *Thread) startSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if t.codexOptions.StartSpan == nil {
+		return ctx, func(error) {}
+	}
+	return t.codexOptions.StartSpan(ctx, name)
+}
+
+// turnOptions applies the thread's default turn options followed by opts.
+func (t *Thread) turnOptions(opts []TurnOption) TurnOptions {
+	merged := make([]TurnOption, 0, len(t.threadOptions.DefaultTurnOptions)+len(opts))
+	merged = append(merged, t.threadOptions.DefaultTurnOptions...)
+	return applyTurnOptions(append(merged, opts...))
 }
 
 // RunStreamed streams events for a single agent turn.
 // Callers should drain Events and then invoke Wait to retrieve any terminal error.
 func (t *Thread) RunStreamed(ctx context.Context, input Input, opts ...TurnOption) (*StreamedTurn, error) {
-	return t.runStreamedInternal(ctx, input, opts)
+	return t.runStreamedInternal(ctx, input, nil, t.codexOptions.EventFilter, opts)
 }
 
-func (t *Thread) runStreamedInternal(ctx context.Context, input Input, opts []TurnOption) (*StreamedTurn, error) {
-	turnOptions := applyTurnOptions(opts)
+// RunRaw starts a turn that writes stdin to the CLI verbatim and appends
+// extraArgs to its command line. It bypasses input normalization and
+// WithContext, so images and context blocks are not applied.
+//
+// RunRaw is an escape hatch for CLI features the SDK does not model yet. It
+// is unstable: it may change or be removed once those features are supported.
+func (t *Thread) RunRaw(ctx context.Context, stdin string, extraArgs []string, opts ...TurnOption) (*StreamedTurn, error) {
+	return t.runStreamedInternal(ctx, Input{}, &rawRequest{stdin: stdin, extraArgs: extraArgs}, t.codexOptions.EventFilter, opts)
+}
+
+// rawRequest carries the verbatim stdin and arguments of a RunRaw turn.
+type rawRequest struct {
+	stdin     string
+	extraArgs []string
+}
+
+func (t *Thread) runStreamedInternal(ctx context.Context, input Input, raw *rawRequest, filter func(ThreadEvent) bool, opts []TurnOption) (_ *StreamedTurn, err error) {
+	ctx, endTurn := t.startSpan(ctx, "codex.turn")
+	defer func() {
+		// Once the turn is running, the streaming goroutine ends the span.
+		if err != nil {
+			endTurn(err)
+		}
+	}()
+
+	// Avoid spawning a process that would be killed immediately.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := validateThreadOptions(t.threadOptions); err != nil {
+		return nil, err
+	}
+
+	if id := t.threadOptions.SessionID; id != "" && id != t.currentID() {
+		return nil, fmt.Errorf("%w: thread %s configured with session ID %s", ErrThreadIDMismatch, t.currentID(), id)
+	}
+
+	configOverrides, err := configTOMLOverrides(t.threadOptions.RawConfigTOML)
+	if err != nil {
+		return nil, err
+	}
+
+	turnOptions := t.turnOptions(opts)
+	if err := validateTurnOptions(turnOptions); err != nil {
+		return nil, err
+	}
 
 	schemaFile, err := createOutputSchemaFile(turnOptions.OutputSchema)
 	if err != nil {
 		return nil, err
 	}
+	// Once the turn is running, the streaming goroutine removes the
+	// temporary files.
+	var compressed *compressedImages
+	defer func() {
+		if err != nil {
+			_ = schemaFile.Cleanup()
+			_ = compressed.Cleanup()
+		}
+	}()
 
-	prompt, images, err := normalizeInput(input)
+	var (
+		prompt    string
+		images    []string
+		extraArgs []string
+	)
+	if raw != nil {
+		prompt = raw.stdin
+		extraArgs = raw.extraArgs
+	} else {
+		prompt, images, err = normalizeInput(input)
+		if err != nil {
+			return nil, err
+		}
+		prompt = prependContext(turnOptions.Context, prompt)
+		if rewrite := t.codexOptions.PromptRewriter; rewrite != nil {
+			prompt = rewrite(prompt)
+		}
+	}
+
+	if turnOptions.ImageMaxDimension > 0 {
+		compressed, err = compressImages(images, turnOptions.ImageMaxDimension, turnOptions.ImageQuality)
+		if err != nil {
+			return nil, fmt.Errorf("compress images: %w", err)
+		}
+		images = compressed.paths
+	}
+
+	instructionsFile, err := t.instructionsFile()
+	if err != nil {
+		return nil, fmt.Errorf("write instructions file: %w", err)
+	}
+
+	workingDir, err := t.workingDirectory(ctx)
 	if err != nil {
-		_ = schemaFile.Cleanup()
 		return nil, err
 	}
 
-	stream, err := t.exec.Run(ctx, ExecArgs{
-		Input:                 prompt,
-		BaseURL:               t.codexOptions.BaseURL,
-		APIKey:                t.codexOptions.APIKey,
-		ThreadID:              t.currentID(),
-		Images:                images,
-		Model:                 t.threadOptions.Model,
-		SandboxMode:           t.threadOptions.SandboxMode,
-		WorkingDirectory:      t.threadOptions.WorkingDirectory,
-		SkipGitRepoCheck:      t.threadOptions.SkipGitRepoCheck,
-		OutputSchemaFile:      schemaFile.Path(),
-		ModelReasoningEffort:  t.threadOptions.ModelReasoningEffort,
-		NetworkAccessEnabled:  t.threadOptions.NetworkAccessEnabled,
-		WebSearchEnabled:      t.threadOptions.WebSearchEnabled,
-		ApprovalPolicy:        t.threadOptions.ApprovalPolicy,
-		AdditionalDirectories: t.threadOptions.AdditionalDirectories,
-	})
+	model, err := resolveModel(t.threadOptions)
+	if err != nil {
+		return nil, err
+	}
+	if turnOptions.model != "" {
+		model = turnOptions.model
+	}
+
+	reasoningEffort := t.threadOptions.ModelReasoningEffort
+	if turnOptions.ModelReasoningEffort != "" {
+		reasoningEffort = turnOptions.ModelReasoningEffort
+	}
+
+	var contextWindow int
+	if t.threadOptions.ModelContextWindow != nil {
+		contextWindow = *t.threadOptions.ModelContextWindow
+	}
+
+	var promptCacheKey string
+	if turnOptions.PromptCacheKey != nil {
+		promptCacheKey = *turnOptions.PromptCacheKey
+	}
+
+	// Hooks get the context the turn was started with, not one cancelled
+	// when the turn ends.
+	hookCtx := ctx
+	ctx, cancelRun := context.WithCancelCause(ctx)
+	active, err := t.turns.add(cancelRun)
 	if err != nil {
-		_ = schemaFile.Cleanup()
+		cancelRun(nil)
+		return nil, err
+	}
+
+	// Waiting for a slot happens after registering the turn so that
+	// Shutdown also releases turns that never started.
+	if err := t.slots.acquire(ctx); err != nil {
+		cancelRun(nil)
+		t.turns.remove(active)
+		return nil, err
+	}
+
+	execCtx, endExec := t.startSpan(ctx, "codex.exec")
+	args := ExecArgs{
+		Input:                  prompt,
+		BaseURL:                t.codexOptions.BaseURL,
+		APIKey:                 t.codexOptions.APIKey,
+		ExtraHeaders:           t.codexOptions.ExtraHeaders,
+		ThreadID:               t.currentID(),
+		Images:                 images,
+		Model:                  model,
+		SandboxMode:            t.threadOptions.SandboxMode,
+		WorkingDirectory:       workingDir,
+		SkipGitRepoCheck:       t.threadOptions.SkipGitRepoCheck,
+		Ephemeral:              t.threadOptions.Ephemeral,
+		OutputSchemaFile:       schemaFile.Path(),
+		ModelReasoningEffort:   reasoningEffort,
+		NoReasoningSummary:     t.threadOptions.DisableReasoningSummary,
+		ModelVerbosity:         t.threadOptions.ModelVerbosity,
+		ModelContextWindow:     contextWindow,
+		NetworkAccessEnabled:   t.threadOptions.NetworkAccessEnabled,
+		SandboxExcludeTmpdir:   t.threadOptions.SandboxExcludeTmpdir,
+		SandboxExcludeSlashTmp: t.threadOptions.SandboxExcludeSlashTmp,
+		WebSearchEnabled:       t.threadOptions.WebSearchEnabled,
+		ApprovalPolicy:         t.threadOptions.ApprovalPolicy,
+		AdditionalDirectories:  t.threadOptions.AdditionalDirectories,
+		ShellEnvironmentPolicy: t.threadOptions.ShellEnvironmentPolicy,
+		WritableRoots:          t.threadOptions.WritableRoots,
+		PromptCacheKey:         promptCacheKey,
+		LastMessageFile:        turnOptions.LastMessageFile,
+		InstructionsFile:       instructionsFile,
+		ConfigOverrides:        configOverrides,
+		ExtraArgs:              extraArgs,
+	}
+	if preRun := t.codexOptions.PreRun; preRun != nil {
+		preRun(hookCtx, args)
+	}
+	stream, err := t.exec.Run(execCtx, args)
+	if err != nil {
+		if postRun := t.codexOptions.PostRun; postRun != nil {
+			postRun(hookCtx, nil, err)
+		}
+		endExec(err)
+		t.slots.release()
+		cancelRun(nil)
+		t.turns.remove(active)
 		return nil, err
 	}
 
+	clock := clockOrDefault(t.codexOptions.Clock)
+	var startupTimer Timer
+	if d := t.codexOptions.StartupTimeout; d > 0 {
+		startupTimer = clock.AfterFunc(d, func() {
+			cancelRun(ErrStartupTimeout)
+		})
+	}
+
 	events := make(chan ThreadEvent)
 	errCh := make(chan error, 1)
+	partial := &turnBuilder{}
+	messages := &messageAccumulator{}
 
 	go func() {
 		defer close(events)
+		defer t.turns.remove(active)
+		defer t.slots.release()
+		defer cancelRun(nil)
 		stdout := stream.Stdout()
 		defer stdout.Close()
+		defer t.eventLog.endTurn()
 		defer func() {
 			_ = schemaFile.Cleanup()
+			_ = compressed.Cleanup()
+			if turnOptions.TurnTempFilesHook != nil {
+				var paths []string
+				if path := schemaFile.Path(); path != "" {
+					paths = append(paths, path)
+				}
+				paths = append(paths, compressed.Written()...)
+				turnOptions.TurnTempFilesHook(paths)
+			}
 		}()
 
-		reader := bufio.NewReader(stdout)
+		// Closing stdout on cancellation unblocks a pending read even if a
+		// descendant process still holds the pipe open.
+		stopClose := context.AfterFunc(ctx, func() {
+			_ = stream.Close()
+		})
+		defer stopClose()
+
+		if turnOptions.Progress != nil {
+			stopProgress := startProgress(turnOptions.Progress)
+			defer stopProgress()
+		}
+
+		if startupTimer != nil {
+			defer startupTimer.Stop()
+		}
+
+		var idleTimer Timer
+		if turnOptions.IdleTimeout > 0 {
+			idleTimer = clock.AfterFunc(turnOptions.IdleTimeout, func() {
+				cancelRun(ErrIdleTimeout)
+			})
+			defer idleTimer.Stop()
+		}
+
+		// Decoding successive JSON values rather than splitting lines keeps
+		// the parser working if the CLI emits several events on one line or
+		// pretty-prints an event across lines.
+		reader := &readErrRecorder{r: stdout}
+		decoder := json.NewDecoder(reader)
 		var runErr error
 
+		var validator *eventSequenceValidator
+		if t.threadOptions.StrictEventValidation {
+			validator = &eventSequenceValidator{}
+		}
+
 		for {
 			if ctxErr := ctx.Err(); ctxErr != nil {
 				runErr = ctxErr
 				break
 			}
 
-			line, readErr := reader.ReadBytes('\n')
-			trimmed := bytes.TrimSpace(line)
-			if len(trimmed) > 0 {
-				var event ThreadEvent
-				if err := json.Unmarshal(trimmed, &event); err != nil {
+			// Only time spent waiting on the CLI counts towards the idle
+			// timeout, not time spent blocked on a slow consumer.
+			if idleTimer != nil {
+				idleTimer.Reset(turnOptions.IdleTimeout)
+			}
+			var raw json.RawMessage
+			decodeErr := decoder.Decode(&raw)
+			receivedAt := clock.Now()
+			if idleTimer != nil {
+				idleTimer.Stop()
+			}
+			if startupTimer != nil {
+				startupTimer.Stop()
+			}
+
+			if decodeErr != nil {
+				switch {
+				case errors.Is(decodeErr, io.EOF):
+				case reader.err != nil && ctx.Err() != nil:
+					// A read failing after cancellation is the pipe being
+					// closed by the AfterFunc above.
+					runErr = ctx.Err()
+				case reader.err != nil:
+					runErr = fmt.Errorf("read codex output: %w", reader.err)
+				default:
+					runErr = fmt.Errorf("parse codex event: %w", decodeErr)
+				}
+				break
+			}
+			t.eventLog.write(raw)
+
+			var event ThreadEvent
+			if err := json.Unmarshal(raw, &event); err != nil {
+				runErr = fmt.Errorf("parse codex event: %w", err)
+				break
+			}
+			if t.codexOptions.DisallowUnknownFields {
+				if err := checkUnknownFields(raw, event); err != nil {
 					runErr = fmt.Errorf("parse codex event: %w", err)
 					break
 				}
+			}
+			event.ReceivedAt = receivedAt
 
-				if event.Type == EventThreadStarted && event.ThreadID != "" {
-					t.setID(event.ThreadID)
+			if cmd, ok := event.Item.(*CommandExecutionItem); ok && t.codexOptions.CommandOutputLimit > 0 {
+				cmd.AggregatedOutput = truncateOutput(cmd.AggregatedOutput, t.codexOptions.CommandOutputLimit)
+			}
+			if msg, ok := event.Item.(*AgentMessageItem); ok {
+				// Stripping first keeps escape codes out of MessageText too.
+				if turnOptions.StripANSI {
+					msg.Text = stripANSI(msg.Text)
+					msg.Delta = stripANSI(msg.Delta)
 				}
+				messages.apply(msg)
+			}
 
-				select {
-				case events <- event:
-				case <-ctx.Done():
-					runErr = ctx.Err()
+			if validator != nil {
+				if err := validator.check(event); err != nil {
+					runErr = err
 					break
 				}
 			}
 
-			if readErr != nil {
-				if errors.Is(readErr, io.EOF) {
+			if event.Type == EventThreadStarted {
+				if err := t.adoptID(event.ThreadID); err != nil {
+					runErr = err
 					break
 				}
-				if runErr == nil {
-					runErr = fmt.Errorf("read codex output: %w", readErr)
-				}
-				break
 			}
 
+			partial.add(event)
+			if event.Type == EventItemCompleted && event.Item != nil {
+				t.recordHistory(event.Item)
+			}
+
+			if filter != nil && event.Item != nil && !filter(event) {
+				continue
+			}
+
+			sendStart := clock.Now()
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				runErr = ctx.Err()
+			}
 			if runErr != nil {
 				break
 			}
+			if observe := turnOptions.BackpressureObserver; observe != nil {
+				if waited := clock.Now().Sub(sendStart); waited >= backpressureThreshold {
+					observe(waited)
+				}
+			}
 		}
 
 		waitErr := stream.Wait()
+		endExec(waitErr)
 		if runErr == nil {
 			runErr = waitErr
 		} else if waitErr != nil && !errors.Is(runErr, waitErr) {
 			runErr = fmt.Errorf("%w; wait error: %v", runErr, waitErr)
 		}
 
+		switch cause := context.Cause(ctx); {
+		case errors.Is(cause, ErrStartupTimeout):
+			runErr = fmt.Errorf("%w: no event received within %s of starting codex", ErrStartupTimeout, t.codexOptions.StartupTimeout)
+		case errors.Is(cause, ErrIdleTimeout):
+			runErr = fmt.Errorf("%w: no event received for %s", ErrIdleTimeout, turnOptions.IdleTimeout)
+		case errors.Is(cause, ErrShutdown):
+			runErr = ErrShutdown
+		case errors.Is(cause, ErrInterrupted):
+			runErr = ErrInterrupted
+		}
+
+		if postRun := t.codexOptions.PostRun; postRun != nil {
+			// Run cancels the process once it sees turn.failed, so the
+			// failure takes precedence over the resulting cancellation.
+			turn, turnErr := partial.turn, runErr
+			if partial.failure != nil && (turnErr == nil || errors.Is(turnErr, context.Canceled)) {
+				turnErr = turnFailureError(partial.failure)
+			}
+			postRun(hookCtx, &turn, turnErr)
+		}
+
+		endTurn(runErr)
 		errCh <- runErr
 	}()
 
 	return &StreamedTurn{
 		Events: events,
+		pid:    stream.PID(),
 		waitFn: func() error {
 			return <-errCh
 		},
+		cancel:      cancelRun,
+		done:        hookCtx.Done(),
+		interrupted: make(chan struct{}),
+		partial:     partial,
+		messages:    messages,
 	}, nil
 }
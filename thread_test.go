@@ -0,0 +1,1893 @@
+package codex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createFakeCodexEventsScript creates a fake codex script that drains stdin and
+// prints the given JSONL events to stdout.
+func createFakeCodexEventsScript(t *testing.T, events ...string) string {
+	t.Helper()
+	return createFakeCodexShellScript(t, "cat > /dev/null\ncat <<'EOF'\n"+strings.Join(events, "\n")+"\nEOF\n")
+}
+
+// createFakeCodexShellScript creates a fake codex script with the given shell body.
+func createFakeCodexShellScript(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake codex shell scripts are not supported on windows")
+	}
+
+	scriptPath := filepath.Join(t.TempDir(), "fake-codex.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("failed to create fake codex script: %v", err)
+	}
+	return scriptPath
+}
+
+// newFakeThread starts a thread backed by the given fake codex script.
+func newFakeThread(t *testing.T, scriptPath string, opts ...ThreadOption) *Thread {
+	t.Helper()
+	client, err := New(WithCodexPath(scriptPath))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client.StartThread(opts...)
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestThreadRunString(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"item.completed","item":{"id":"item-1","type":"agent_message","text":"first"}}`,
+		`{"type":"item.completed","item":{"id":"item-2","type":"agent_message","text":"final answer"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+	ctx := testContext(t)
+
+	turn, err := thread.Run(ctx, Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, err := thread.RunString(ctx, "hello")
+	if err != nil {
+		t.Fatalf("RunString failed: %v", err)
+	}
+	if got != turn.FinalResponse {
+		t.Errorf("expected %q, got %q", turn.FinalResponse, got)
+	}
+	if got != "final answer" {
+		t.Errorf("expected %q, got %q", "final answer", got)
+	}
+}
+
+func TestRunStreamedCommandOutputUpdates(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.started","item":{"id":"cmd-1","type":"command_execution","command":"make","aggregated_output":"","status":"in_progress"}}`,
+		`{"type":"item.updated","item":{"id":"cmd-1","type":"command_execution","command":"make","aggregated_output":"step 1\n","status":"in_progress"}}`,
+		`{"type":"item.updated","item":{"id":"cmd-1","type":"command_execution","command":"make","aggregated_output":"step 1\nstep 2\n","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"cmd-1","type":"command_execution","command":"make","aggregated_output":"step 1\nstep 2\ndone\n","exit_code":0,"status":"completed"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("build"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+
+	var outputs []string
+	for event := range streamed.Events {
+		if event.Type != EventItemUpdated {
+			continue
+		}
+		cmd, ok := event.Item.(*CommandExecutionItem)
+		if !ok {
+			t.Fatalf("expected *CommandExecutionItem, got %T", event.Item)
+		}
+		if cmd.Status != CommandStatusInProgress {
+			t.Errorf("expected status %q, got %q", CommandStatusInProgress, cmd.Status)
+		}
+		outputs = append(outputs, cmd.AggregatedOutput)
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	want := []string{"step 1\n", "step 1\nstep 2\n"}
+	if len(outputs) != len(want) {
+		t.Fatalf("expected %d updates, got %d: %q", len(want), len(outputs), outputs)
+	}
+	for i := range want {
+		if outputs[i] != want[i] {
+			t.Errorf("update %d: expected %q, got %q", i, want[i], outputs[i])
+		}
+	}
+}
+
+func TestRunIdleTimeout(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+exec sleep 5
+`)
+	thread := newFakeThread(t, script)
+
+	start := time.Now()
+	_, err := thread.Run(testContext(t), Text("hello"), WithIdleTimeout(200*time.Millisecond))
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected idle timeout to cancel promptly, took %s", elapsed)
+	}
+}
+
+func TestRunIdleTimeoutResetsOnEvents(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+sleep 0.2
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"still working"}}'
+sleep 0.2
+echo '{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"done"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	turn, err := thread.Run(testContext(t), Text("hello"), WithIdleTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if turn.FinalResponse != "done" {
+		t.Errorf("expected final response %q, got %q", "done", turn.FinalResponse)
+	}
+}
+
+func TestRunCancelledMidTurn(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+exec sleep 30
+`)
+	thread := newFakeThread(t, script)
+
+	ctx, cancel := context.WithCancel(testContext(t))
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := thread.Run(ctx, Text("hello"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected cancellation to return promptly, took %s", elapsed)
+	}
+}
+
+func TestRunTurnFailedDoesNotLeak(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args.txt")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+for arg in "$@"; do printf '%s\n' "$arg"; done > '`+argsFile+`'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.failed","error":{"message":"boom"}}'
+i=0
+while [ $i -lt 200 ]; do
+  echo '{"type":"item.completed","item":{"id":"late","type":"agent_message","text":"late"}}'
+  i=$((i+1))
+done
+`)
+	thread := newFakeThread(t, script)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 5; i++ {
+		_, err := thread.Run(testContext(t), Text("hello"), WithOutputSchema(map[string]any{"type": "object"}))
+		if err == nil || err.Error() != "boom" {
+			t.Fatalf("expected turn failure %q, got %v", "boom", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutine leak: %d goroutines before, %d after", before, after)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("failed to read captured args: %v", err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i, arg := range args {
+		if arg == "--output-schema" && i+1 < len(args) {
+			if _, err := os.Stat(args[i+1]); !os.IsNotExist(err) {
+				t.Errorf("expected schema file %s to be removed, stat err: %v", args[i+1], err)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected --output-schema in args, got %q", args)
+}
+
+// createFakeCodexArgsRecorder creates a fake codex script that records the
+// arguments of its latest invocation and emits a successful turn.
+func createFakeCodexArgsRecorder(t *testing.T) (scriptPath, argsFile string) {
+	t.Helper()
+	argsFile = filepath.Join(t.TempDir(), "args.txt")
+	scriptPath = createFakeCodexShellScript(t, `cat > /dev/null
+for arg in "$@"; do printf '%s\n' "$arg"; done > '`+argsFile+`'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"ok"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	return scriptPath, argsFile
+}
+
+// readRecordedArgs returns the arguments recorded by createFakeCodexArgsRecorder.
+func readRecordedArgs(t *testing.T, argsFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("failed to read captured args: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestRunReasoningEffortOverride(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithModelReasoningEffort(ReasoningXHigh))
+	ctx := testContext(t)
+
+	if _, err := thread.Run(ctx, Text("trivial"), WithReasoningEffort(ReasoningLow)); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--config", `model_reasoning_effort="low"`) {
+		t.Errorf("expected turn-level effort in args, got %q", args)
+	}
+	if containsArgPair(args, "--config", `model_reasoning_effort="xhigh"`) {
+		t.Errorf("thread-level effort should be overridden, got %q", args)
+	}
+
+	if _, err := thread.Run(ctx, Text("hard")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args = readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--config", `model_reasoning_effort="xhigh"`) {
+		t.Errorf("expected thread-level effort to be restored, got %q", args)
+	}
+}
+
+func TestRunStreamedPID(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid.txt")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo $$ > '`+pidFile+`'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	for range streamed.Events {
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	if streamed.PID() == 0 {
+		t.Fatal("expected nonzero PID")
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("failed to read pid file: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(streamed.PID()) {
+		t.Errorf("expected PID %d, process reported %s", streamed.PID(), got)
+	}
+
+	if (&StreamedTurn{}).PID() != 0 {
+		t.Error("expected zero PID for a turn without a process")
+	}
+}
+
+type flushRecorder struct {
+	buf     bytes.Buffer
+	chunks  []string
+	flushes int
+}
+
+func (f *flushRecorder) Write(p []byte) (int, error) {
+	f.chunks = append(f.chunks, string(p))
+	return f.buf.Write(p)
+}
+
+func (f *flushRecorder) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestThreadRunTo(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"r1","type":"reasoning","text":"thinking"}}`,
+		`{"type":"item.updated","item":{"id":"m1","type":"agent_message","text":"Hel"}}`,
+		`{"type":"item.updated","item":{"id":"m1","type":"agent_message","text":"Hello, wor"}}`,
+		`{"type":"item.completed","item":{"id":"m1","type":"agent_message","text":"Hello, world"}}`,
+		`{"type":"item.completed","item":{"id":"m2","type":"agent_message","text":"Bye"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	var out flushRecorder
+	turn, err := thread.RunTo(testContext(t), Text("hello"), &out)
+	if err != nil {
+		t.Fatalf("RunTo failed: %v", err)
+	}
+
+	if got, want := out.buf.String(), "Hello, world\nBye"; got != want {
+		t.Errorf("expected written output %q, got %q", want, got)
+	}
+	wantChunks := []string{"Hel", "lo, wor", "ld", "\nBye"}
+	if !reflect.DeepEqual(out.chunks, wantChunks) {
+		t.Errorf("expected chunks %q, got %q", wantChunks, out.chunks)
+	}
+	if out.flushes != len(wantChunks) {
+		t.Errorf("expected %d flushes, got %d", len(wantChunks), out.flushes)
+	}
+	if turn.FinalResponse != "Bye" {
+		t.Errorf("expected final response %q, got %q", "Bye", turn.FinalResponse)
+	}
+	if len(turn.Items) != 3 {
+		t.Errorf("expected 3 items, got %d", len(turn.Items))
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestThreadRunToWriteError(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"item.completed","item":{"id":"m1","type":"agent_message","text":"Hello"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	_, err := thread.RunTo(testContext(t), Text("hello"), failingWriter{})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected write error, got %v", err)
+	}
+}
+
+func TestRunWithContextPrompt(t *testing.T) {
+	stdinFile := filepath.Join(t.TempDir(), "stdin.txt")
+	script := createFakeCodexShellScript(t, `cat > '`+stdinFile+`'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	_, err := thread.Run(testContext(t), Text("Fix the bug"),
+		WithContext("Repository uses Go 1.22."),
+		WithContext("Tests live next to sources."))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatalf("failed to read stdin: %v", err)
+	}
+	want := "Repository uses Go 1.22.\n\nTests live next to sources.\n\n---\n\nFix the bug"
+	if string(data) != want {
+		t.Errorf("expected prompt %q, got %q", want, data)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args.txt")
+	script := createFakeCodexShellScript(t, `prompt=$(cat)
+printf '%s\n' "$*" >> '`+argsFile+`'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+if [ "$prompt" = "fail" ]; then
+  echo '{"type":"turn.failed","error":{"message":"boom"}}'
+  exit 0
+fi
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"re: '"$prompt"'"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+
+	t.Run("all succeed", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script)
+		turns, err := thread.RunBatch(testContext(t), []Input{Text("one"), Text("two"), Text("three")})
+		if err != nil {
+			t.Fatalf("RunBatch failed: %v", err)
+		}
+		var responses []string
+		for _, turn := range turns {
+			responses = append(responses, turn.FinalResponse)
+		}
+		if want := []string{"re: one", "re: two", "re: three"}; !slices.Equal(responses, want) {
+			t.Errorf("expected responses %q, got %q", want, responses)
+		}
+
+		data, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		invocations := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(invocations) != 3 {
+			t.Fatalf("expected 3 invocations, got %q", invocations)
+		}
+		if strings.Contains(invocations[0], "resume") {
+			t.Errorf("expected the first turn to start a new thread, got %q", invocations[0])
+		}
+		for _, args := range invocations[1:] {
+			if !strings.HasSuffix(args, "resume thread-1") {
+				t.Errorf("expected later turns to resume thread-1, got %q", args)
+			}
+		}
+	})
+
+	t.Run("stops on first error", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script)
+		turns, err := thread.RunBatch(testContext(t), []Input{Text("one"), Text("fail"), Text("three")})
+		if err == nil || !strings.Contains(err.Error(), "batch input 1: boom") {
+			t.Fatalf("expected error for input 1, got %v", err)
+		}
+		if len(turns) != 1 || turns[0].FinalResponse != "re: one" {
+			t.Errorf("expected the completed first turn, got %d turns", len(turns))
+		}
+
+		data, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		if n := strings.Count(string(data), "\n"); n != 2 {
+			t.Errorf("expected 2 invocations before stopping, got %d", n)
+		}
+	})
+}
+
+func TestRunAutoResumeFallback(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args.txt")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+printf '%s\n' "$*" >> '`+argsFile+`'
+case " $* " in
+*" resume "*)
+  echo "Error: no session found with ID thread-old" >&2
+  exit 1
+  ;;
+esac
+echo '{"type":"thread.started","thread_id":"thread-new"}'
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"fresh start"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+
+	t.Run("without fallback", func(t *testing.T) {
+		client, err := New(WithCodexPath(script))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		_, err = client.ResumeThread("thread-old").Run(testContext(t), Text("hello"))
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound, got %v", err)
+		}
+		var execErr *ErrExecFailed
+		if !errors.As(err, &execErr) || execErr.ExitCode != 1 {
+			t.Errorf("expected the exec failure to be wrapped, got %v", err)
+		}
+	})
+
+	t.Run("with fallback", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		var logs bytes.Buffer
+		client, err := New(WithCodexPath(script), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		thread := client.ResumeThread("thread-old", WithAutoResumeFallback())
+
+		turn, err := thread.Run(testContext(t), Text("hello"))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if turn.FinalResponse != "fresh start" {
+			t.Errorf("expected response from the new session, got %q", turn.FinalResponse)
+		}
+		if thread.ID() != "thread-new" {
+			t.Errorf("expected thread to adopt the new ID, got %q", thread.ID())
+		}
+		if !strings.Contains(logs.String(), "codex session not found") {
+			t.Errorf("expected a warning to be logged, got %q", logs.String())
+		}
+
+		data, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		invocations := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(invocations) != 2 || !strings.HasSuffix(invocations[0], "resume thread-old") || strings.Contains(invocations[1], "resume") {
+			t.Errorf("expected a failed resume followed by a new thread, got %q", invocations)
+		}
+	})
+}
+
+func TestRunModelFallback(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args.txt")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+printf '%s\n' "$*" >> '`+argsFile+`'
+case " $* " in
+*" --model gpt-5 "*)
+  echo '{"type":"thread.started","thread_id":"thread-1"}'
+  echo '{"type":"turn.failed","error":{"message":"The model gpt-5 does not exist","code":"model_not_found"}}'
+  ;;
+*" --model gpt-4.1 "*)
+  echo "Error: unsupported model gpt-4.1" >&2
+  exit 1
+  ;;
+*" --model edit-then-fail "*)
+  echo '{"type":"thread.started","thread_id":"thread-1"}'
+  echo '{"type":"item.completed","item":{"id":"1","type":"file_change","changes":[{"path":"a.go","kind":"update"}],"status":"completed"}}'
+  echo '{"type":"turn.failed","error":{"message":"The model edit-then-fail does not exist","code":"model_not_found"}}'
+  ;;
+*" --model mcp-then-fail "*)
+  echo '{"type":"thread.started","thread_id":"thread-1"}'
+  echo '{"type":"item.completed","item":{"id":"1","type":"mcp_tool_call","server":"s","tool":"t","status":"completed"}}'
+  echo '{"type":"turn.failed","error":{"message":"The model mcp-then-fail does not exist","code":"model_not_found"}}'
+  ;;
+*" --model overloaded "*)
+  echo '{"type":"thread.started","thread_id":"thread-1"}'
+  echo '{"type":"turn.failed","error":{"message":"model is overloaded and unavailable"}}'
+  ;;
+*)
+  echo '{"type":"thread.started","thread_id":"thread-2"}'
+  echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"answered"}}'
+  echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+  ;;
+esac
+`)
+	invocations := func(t *testing.T) []string {
+		t.Helper()
+		data, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("failed to read args: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	t.Run("falls back", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script, WithModel("gpt-5"), WithModelFallback("gpt-4.1", "gpt-4o"))
+		turn, err := thread.Run(testContext(t), Text("hello"))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if turn.FinalResponse != "answered" {
+			t.Errorf("expected response from the fallback model, got %q", turn.FinalResponse)
+		}
+
+		got := invocations(t)
+		if len(got) != 3 {
+			t.Fatalf("expected 3 attempts, got %q", got)
+		}
+		for i, model := range []string{"gpt-5", "gpt-4.1", "gpt-4o"} {
+			if !strings.Contains(got[i], "--model "+model) {
+				t.Errorf("expected attempt %d to use %s, got %q", i, model, got[i])
+			}
+			if strings.Contains(got[i], "resume") {
+				t.Errorf("expected attempt %d to start a new thread, got %q", i, got[i])
+			}
+		}
+		if thread.ID() != "thread-2" {
+			t.Errorf("expected the thread ID of the successful attempt, got %q", thread.ID())
+		}
+	})
+
+	t.Run("without fallback", func(t *testing.T) {
+		thread := newFakeThread(t, script, WithModel("gpt-5"))
+		_, err := thread.Run(testContext(t), Text("hello"))
+		if !errors.Is(err, ErrModelUnavailable) {
+			t.Fatalf("expected ErrModelUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("no fallback after side effects", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script, WithModel("edit-then-fail"), WithModelFallback("gpt-4o"))
+		_, err := thread.Run(testContext(t), Text("hello"))
+		if !errors.Is(err, ErrModelUnavailable) {
+			t.Fatalf("expected ErrModelUnavailable, got %v", err)
+		}
+		if got := invocations(t); len(got) != 1 {
+			t.Errorf("expected no retry after a file change, got %q", got)
+		}
+	})
+
+	t.Run("no fallback after an MCP tool call", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script, WithModel("mcp-then-fail"), WithModelFallback("gpt-4o"))
+		_, err := thread.Run(testContext(t), Text("hello"))
+		if !errors.Is(err, ErrModelUnavailable) {
+			t.Fatalf("expected ErrModelUnavailable, got %v", err)
+		}
+		if got := invocations(t); len(got) != 1 {
+			t.Errorf("expected no retry after an MCP tool call, got %q", got)
+		}
+	})
+
+	t.Run("no fallback when overloaded", func(t *testing.T) {
+		_ = os.Remove(argsFile)
+		thread := newFakeThread(t, script, WithModel("overloaded"), WithModelFallback("gpt-4o"))
+		_, err := thread.Run(testContext(t), Text("hello"))
+		if err == nil || errors.Is(err, ErrModelUnavailable) {
+			t.Fatalf("expected a plain turn failure, got %v", err)
+		}
+		if got := invocations(t); len(got) != 1 {
+			t.Errorf("expected no retry for an overloaded model, got %q", got)
+		}
+	})
+}
+
+func TestRunContextLengthExceeded(t *testing.T) {
+	tests := map[string]string{
+		"turn failed code": `echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.failed","error":{"message":"input too long","code":"context_length_exceeded"}}'`,
+		"turn failed message": `echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.failed","error":{"message":"This model'"'"'s maximum context length is 272000 tokens."}}'`,
+		"stderr": `echo "Error: Your input exceeds the context window of this model." >&2
+exit 1`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			thread := newFakeThread(t, createFakeCodexShellScript(t, "cat > /dev/null\n"+body+"\n"))
+			_, err := thread.Run(testContext(t), Text("hello"))
+			if !errors.Is(err, ErrContextLengthExceeded) {
+				t.Fatalf("expected ErrContextLengthExceeded, got %v", err)
+			}
+		})
+	}
+
+	thread := newFakeThread(t, createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.failed","error":{"message":"boom"}}`,
+	))
+	if _, err := thread.Run(testContext(t), Text("hello")); err == nil || errors.Is(err, ErrContextLengthExceeded) {
+		t.Errorf("expected an unclassified failure, got %v", err)
+	}
+}
+
+func TestRunPreAndPostRunHooks(t *testing.T) {
+	script := createFakeCodexShellScript(t, `prompt=$(cat)
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+if [ "$prompt" = "fail" ]; then
+  echo '{"type":"turn.failed","error":{"message":"boom"}}'
+  exit 0
+fi
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"done"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":3,"cached_input_tokens":0,"output_tokens":2}}'
+`)
+
+	type ctxKey struct{}
+	var (
+		calls    []string
+		preArgs  []ExecArgs
+		postErrs []error
+		turns    []*Turn
+	)
+	client, err := New(WithCodexPath(script),
+		WithPreRun(func(ctx context.Context, args ExecArgs) {
+			calls = append(calls, "pre:"+ctx.Value(ctxKey{}).(string))
+			preArgs = append(preArgs, args)
+		}),
+		WithPostRun(func(ctx context.Context, turn *Turn, err error) {
+			calls = append(calls, "post:"+ctx.Value(ctxKey{}).(string))
+			turns = append(turns, turn)
+			postErrs = append(postErrs, err)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.StartThread(WithModel("gpt-5"))
+
+	ctx := context.WithValue(testContext(t), ctxKey{}, "first")
+	if _, err := thread.Run(ctx, Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	ctx = context.WithValue(testContext(t), ctxKey{}, "second")
+	if _, err := thread.Run(ctx, Text("fail")); err == nil {
+		t.Fatal("expected the second turn to fail")
+	}
+
+	if want := []string{"pre:first", "post:first", "pre:second", "post:second"}; !slices.Equal(calls, want) {
+		t.Fatalf("expected hook calls %q, got %q", want, calls)
+	}
+	if preArgs[0].Input != "hello" || preArgs[0].Model != "gpt-5" || preArgs[0].ThreadID != "" {
+		t.Errorf("unexpected args for the first turn: %+v", preArgs[0])
+	}
+	if preArgs[1].Input != "fail" || preArgs[1].ThreadID != "thread-1" {
+		t.Errorf("unexpected args for the second turn: %+v", preArgs[1])
+	}
+
+	if postErrs[0] != nil || turns[0] == nil || turns[0].FinalResponse != "done" || turns[0].Usage == nil || turns[0].Usage.InputTokens != 3 {
+		t.Errorf("expected the completed turn, got %+v (err=%v)", turns[0], postErrs[0])
+	}
+	if postErrs[1] == nil || postErrs[1].Error() != "boom" {
+		t.Errorf("expected the turn failure, got %v", postErrs[1])
+	}
+}
+
+func TestRunPreAndPostRunHooksWithModelFallback(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+case " $* " in
+*" --model gpt-5 "*)
+  echo '{"type":"turn.failed","error":{"message":"The model gpt-5 does not exist","code":"model_not_found"}}'
+  ;;
+*)
+  echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+  ;;
+esac
+`)
+
+	var (
+		calls    []string
+		postErrs []error
+	)
+	client, err := New(WithCodexPath(script),
+		WithPreRun(func(ctx context.Context, args ExecArgs) {
+			calls = append(calls, "pre:"+args.Model)
+		}),
+		WithPostRun(func(ctx context.Context, turn *Turn, err error) {
+			calls = append(calls, "post")
+			postErrs = append(postErrs, err)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.StartThread(WithModel("gpt-5"), WithModelFallback("gpt-4.1"))
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// Each attempt is its own process, so the hooks fire once per attempt.
+	if want := []string{"pre:gpt-5", "post", "pre:gpt-4.1", "post"}; !slices.Equal(calls, want) {
+		t.Fatalf("expected hook calls %q, got %q", want, calls)
+	}
+	if !errors.Is(postErrs[0], ErrModelUnavailable) || postErrs[1] != nil {
+		t.Errorf("expected the failed attempt then the successful one, got %v", postErrs)
+	}
+}
+
+func TestRunWithPromptRewriter(t *testing.T) {
+	stdinFile := filepath.Join(t.TempDir(), "stdin.txt")
+	script := createFakeCodexShellScript(t, `cat > '`+stdinFile+`'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	var seen string
+	client, err := New(WithCodexPath(script), WithPromptRewriter(func(prompt string) string {
+		seen = prompt
+		return strings.ReplaceAll(prompt, "sk-secret", "[REDACTED]")
+	}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = client.StartThread().Run(testContext(t), Text("Use key sk-secret"), WithContext("Context."))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if want := "Context.\n\n---\n\nUse key sk-secret"; seen != want {
+		t.Errorf("expected rewriter to see normalized prompt %q, got %q", want, seen)
+	}
+	data, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatalf("failed to read stdin: %v", err)
+	}
+	if want := "Context.\n\n---\n\nUse key [REDACTED]"; string(data) != want {
+		t.Errorf("expected rewritten prompt %q, got %q", want, data)
+	}
+}
+
+func TestRunRecordsTurnTiming(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.started"}'
+sleep 0.2
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	turn, err := thread.Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if turn.StartedAt.IsZero() || turn.CompletedAt.IsZero() {
+		t.Fatalf("expected start and completion times, got %v and %v", turn.StartedAt, turn.CompletedAt)
+	}
+	if d := turn.Duration(); d < 150*time.Millisecond {
+		t.Errorf("expected duration of at least 150ms, got %s", d)
+	}
+}
+
+func TestTurnDurationWithoutTimestamps(t *testing.T) {
+	if d := (&Turn{CompletedAt: time.Now()}).Duration(); d != 0 {
+		t.Errorf("expected zero duration without a start time, got %s", d)
+	}
+}
+
+func TestRunMismatchedThreadStarted(t *testing.T) {
+	events := []string{
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"thread.started","thread_id":"thread-2"}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	}
+
+	t.Run("warns_and_keeps_id", func(t *testing.T) {
+		script := createFakeCodexEventsScript(t, events...)
+		var logs bytes.Buffer
+		client, err := New(WithCodexPath(script), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		thread := client.StartThread()
+
+		if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if thread.ID() != "thread-1" {
+			t.Errorf("expected thread ID to stay %q, got %q", "thread-1", thread.ID())
+		}
+		if !strings.Contains(logs.String(), "reported_thread_id=thread-2") {
+			t.Errorf("expected mismatch warning, got logs %q", logs.String())
+		}
+	})
+
+	t.Run("strict_fails_turn", func(t *testing.T) {
+		script := createFakeCodexEventsScript(t, events...)
+		thread := newFakeThread(t, script, WithStrictThreadID())
+
+		_, err := thread.Run(testContext(t), Text("hello"))
+		if !errors.Is(err, ErrThreadIDMismatch) {
+			t.Fatalf("expected ErrThreadIDMismatch, got %v", err)
+		}
+		if thread.ID() != "thread-1" {
+			t.Errorf("expected thread ID to stay %q, got %q", "thread-1", thread.ID())
+		}
+	})
+
+	t.Run("resumed_thread", func(t *testing.T) {
+		script := createFakeCodexEventsScript(t, events[1:]...)
+		client, err := New(WithCodexPath(script))
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		thread := client.ResumeThread("thread-1")
+
+		if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if thread.ID() != "thread-1" {
+			t.Errorf("expected resumed thread ID to stay %q, got %q", "thread-1", thread.ID())
+		}
+	})
+}
+
+// lastMessageScript writes message to the --output-last-message path and then
+// prints the given events.
+func lastMessageScript(t *testing.T, message string, events ...string) string {
+	t.Helper()
+	return createFakeCodexShellScript(t, `cat > /dev/null
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--output-last-message" ]; then printf '%s' '`+message+`' > "$2"; fi
+  shift
+done
+cat <<'EOF'
+`+strings.Join(events, "\n")+`
+EOF
+`)
+}
+
+func TestRunLastMessageFile(t *testing.T) {
+	completed := `{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`
+
+	t.Run("event_based", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "last.txt")
+		script := lastMessageScript(t, "from file",
+			`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"from event"}}`,
+			completed)
+		thread := newFakeThread(t, script)
+
+		turn, err := thread.Run(testContext(t), Text("hello"), WithLastMessageFile(path))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if turn.FinalResponse != "from event" {
+			t.Errorf("expected final response %q, got %q", "from event", turn.FinalResponse)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "from file" {
+			t.Errorf("expected CLI to write last message file, got %q", data)
+		}
+	})
+
+	t.Run("file_based", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "last.txt")
+		script := lastMessageScript(t, "from file", completed)
+		thread := newFakeThread(t, script)
+
+		turn, err := thread.Run(testContext(t), Text("hello"), WithLastMessageFile(path))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if turn.FinalResponse != "from file" {
+			t.Errorf("expected final response %q, got %q", "from file", turn.FinalResponse)
+		}
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "never-written.txt")
+		thread := newFakeThread(t, createFakeCodexEventsScript(t, completed))
+
+		turn, err := thread.Run(testContext(t), Text("hello"), WithLastMessageFile(path))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if turn.FinalResponse != "" {
+			t.Errorf("expected empty final response, got %q", turn.FinalResponse)
+		}
+	})
+}
+
+func TestRunLargeEventLine(t *testing.T) {
+	output := strings.Repeat("x", 4*1024*1024)
+	script := createFakeCodexEventsScript(t,
+		`{"type":"item.completed","item":{"id":"cmd-1","type":"command_execution","command":"cat big","aggregated_output":"`+output+`","exit_code":0,"status":"completed"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+
+	turn, err := newFakeThread(t, script).Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(turn.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(turn.Items))
+	}
+	cmd, ok := turn.Items[0].(*CommandExecutionItem)
+	if !ok {
+		t.Fatalf("expected *CommandExecutionItem, got %T", turn.Items[0])
+	}
+	if len(cmd.AggregatedOutput) != len(output) {
+		t.Errorf("expected %d bytes of output, got %d", len(output), len(cmd.AggregatedOutput))
+	}
+}
+
+func TestRunPreCancelledContext(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "started")
+	script := createFakeCodexShellScript(t, `touch '`+marker+`'
+cat > /dev/null
+`)
+	thread := newFakeThread(t, script)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := thread.Run(ctx, Text("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from Run, got %v", err)
+	}
+	if _, err := thread.RunStreamed(ctx, Text("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from RunStreamed, got %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected no subprocess to be started, stat err: %v", err)
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 20 * time.Millisecond
+
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+sleep 0.3
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	var (
+		mu      sync.Mutex
+		calls   int
+		elapsed time.Duration
+	)
+	_, err := thread.Run(testContext(t), Text("hello"), WithProgress(func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		elapsed = d
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	mu.Lock()
+	callsAtReturn := calls
+	if calls == 0 {
+		t.Error("expected progress callback to fire at least once")
+	}
+	if elapsed <= 0 {
+		t.Errorf("expected positive elapsed time, got %s", elapsed)
+	}
+	mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != callsAtReturn {
+		t.Errorf("expected no callbacks after completion, got %d more", calls-callsAtReturn)
+	}
+}
+
+func TestRunRawPassesStdinAndArgs(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	stdinFile := filepath.Join(dir, "stdin.txt")
+	script := createFakeCodexShellScript(t, `cat > '`+stdinFile+`'
+for arg in "$@"; do printf '%s\n' "$arg"; done > '`+argsFile+`'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script, WithModel("gpt-5"))
+
+	const stdin = "{\"raw\": true}\n  untouched  "
+	streamed, err := thread.RunRaw(testContext(t), stdin, []string{"--future-flag", "value"}, WithContext("ignored"))
+	if err != nil {
+		t.Fatalf("RunRaw failed: %v", err)
+	}
+	for range streamed.Events {
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	got, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatalf("failed to read captured stdin: %v", err)
+	}
+	if string(got) != stdin {
+		t.Errorf("expected stdin %q, got %q", stdin, got)
+	}
+
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--future-flag", "value") {
+		t.Errorf("expected extra args, got %q", args)
+	}
+	if !containsArgPair(args, "--model", "gpt-5") {
+		t.Errorf("expected thread options to apply, got %q", args)
+	}
+}
+
+func TestRunScratchDirArgs(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	scratch := t.TempDir()
+	thread := newFakeThread(t, script, WithScratchDir(scratch))
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--sandbox", string(SandboxWorkspaceWrite)) {
+		t.Errorf("expected workspace-write sandbox, got %q", args)
+	}
+	if want := "sandbox_workspace_write.writable_roots=" + tomlStringArray([]string{scratch}); !containsArgPair(args, "--config", want) {
+		t.Errorf("expected %q in args, got %q", want, args)
+	}
+
+	missing := newFakeThread(t, script, WithScratchDir(filepath.Join(scratch, "missing")))
+	var invalidInput *ErrInvalidInput
+	if _, err := missing.Run(testContext(t), Text("hello")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput for missing scratch dir, got %v", err)
+	}
+}
+
+func TestRunEphemeralSkipsSessionFile(t *testing.T) {
+	// The fake CLI persists a session under $CODEX_HOME/sessions unless it
+	// is started with --ephemeral, mirroring the real CLI.
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+ephemeral=0
+for arg in "$@"; do [ "$arg" = "--ephemeral" ] && ephemeral=1; done
+if [ $ephemeral -eq 0 ]; then
+  mkdir -p "$CODEX_HOME/sessions" && echo '{}' > "$CODEX_HOME/sessions/thread-1.jsonl"
+fi
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+
+	for _, tt := range []struct {
+		name      string
+		opts      []ThreadOption
+		wantFiles int
+	}{
+		{name: "persisted", wantFiles: 1},
+		{name: "ephemeral", opts: []ThreadOption{WithEphemeral()}, wantFiles: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			client, err := New(WithCodexPath(script), WithEnv(map[string]string{"CODEX_HOME": home}))
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			thread := client.StartThread(tt.opts...)
+
+			if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if thread.ID() != "thread-1" {
+				t.Errorf("expected in-memory thread ID %q, got %q", "thread-1", thread.ID())
+			}
+			files, _ := filepath.Glob(filepath.Join(home, "sessions", "*"))
+			if len(files) != tt.wantFiles {
+				t.Errorf("expected %d session files, got %q", tt.wantFiles, files)
+			}
+		})
+	}
+}
+
+func TestRunClientExtraArgs(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(WithCodexPath(script), WithExtraArgs("--enable", "future_feature"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.ResumeThread("thread-1", WithModel("gpt-5"))
+
+	if _, err := thread.Run(testContext(t), Text("hello"), WithLastMessageFile(filepath.Join(t.TempDir(), "last.txt"))); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	args := readRecordedArgs(t, argsFile)
+	want := []string{"--enable", "future_feature", "resume", "thread-1"}
+	if len(args) < len(want) || !slices.Equal(args[len(args)-len(want):], want) {
+		t.Fatalf("expected args to end with %q, got %q", want, args)
+	}
+	if !containsArgPair(args, "--model", "gpt-5") || !containsString(args, "--output-last-message") {
+		t.Errorf("expected SDK-generated args before the extra args, got %q", args)
+	}
+}
+
+func TestResumeThreadAppliesOptions(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	dir := t.TempDir()
+	thread := client.ResumeThread("thread-1",
+		WithSandboxMode(SandboxWorkspaceWrite),
+		WithModel("gpt-5"),
+		WithWorkingDirectory(dir),
+		WithSkipGitRepoCheck(),
+		WithApprovalPolicy(ApprovalNever),
+		WithNetworkAccess(true),
+		WithSandboxExcludeSlashTmp(true),
+	)
+
+	if _, err := thread.Run(testContext(t), Text("continue")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	args := readRecordedArgs(t, argsFile)
+	resume := slices.Index(args, "resume")
+	if resume < 0 || resume != len(args)-2 || args[resume+1] != "thread-1" {
+		t.Fatalf("expected args to end with resume thread-1, got %q", args)
+	}
+	flags := args[:resume]
+	for _, pair := range [][2]string{
+		{"--sandbox", "workspace-write"},
+		{"--model", "gpt-5"},
+		{"--cd", dir},
+		{"--config", `approval_policy="never"`},
+		{"--config", "sandbox_workspace_write.network_access=true"},
+		{"--config", "sandbox_workspace_write.exclude_slash_tmp=true"},
+	} {
+		if !containsArgPair(flags, pair[0], pair[1]) {
+			t.Errorf("expected %s %s before the resume subcommand, got %q", pair[0], pair[1], args)
+		}
+	}
+	if !containsString(flags, "--skip-git-repo-check") {
+		t.Errorf("expected --skip-git-repo-check before the resume subcommand, got %q", args)
+	}
+}
+
+func TestStreamedTurnNext(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"reasoning","text":"thinking"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"first"}}`,
+		`{"type":"item.completed","item":{"id":"3","type":"agent_message","text":"second"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+	ctx := testContext(t)
+
+	streamed, err := thread.RunStreamed(ctx, Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+
+	isMessage := func(e ThreadEvent) bool {
+		_, ok := e.Item.(*AgentMessageItem)
+		return ok
+	}
+	for _, want := range []string{"first", "second"} {
+		event, ok := streamed.Next(ctx, isMessage)
+		if !ok {
+			t.Fatalf("expected agent message %q, stream ended", want)
+		}
+		if got := event.Item.(*AgentMessageItem).Text; got != want {
+			t.Errorf("expected agent message %q, got %q", want, got)
+		}
+	}
+
+	if event, ok := streamed.Next(ctx, isMessage); ok {
+		t.Errorf("expected no further match, got %+v", event)
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+}
+
+func TestStreamedTurnNextContextDone(t *testing.T) {
+	events := make(chan ThreadEvent)
+	streamed := &StreamedTurn{Events: events}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, ok := streamed.Next(ctx, func(ThreadEvent) bool { return true }); ok {
+		t.Error("expected Next to give up on a cancelled context")
+	}
+}
+
+func TestRunDefaultTurnOptions(t *testing.T) {
+	schemaCopy := filepath.Join(t.TempDir(), "schema.json")
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--output-schema" ]; then cp "$2" '`+schemaCopy+`'; fi
+  shift
+done
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+sleep 0.5
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	defaultSchema := map[string]any{"type": "object", "title": "default"}
+	thread := newFakeThread(t, script, WithDefaultTurnOptions(
+		WithOutputSchema(defaultSchema),
+		WithIdleTimeout(100*time.Millisecond),
+	))
+
+	if _, err := thread.Run(testContext(t), Text("hello")); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected default idle timeout to apply, got %v", err)
+	}
+	if data, err := os.ReadFile(schemaCopy); err != nil || !strings.Contains(string(data), `"default"`) {
+		t.Errorf("expected default schema, got %q (err=%v)", data, err)
+	}
+
+	callSchema := map[string]any{"type": "object", "title": "per-call"}
+	if _, err := thread.Run(testContext(t), Text("hello"), WithOutputSchema(callSchema), WithIdleTimeout(5*time.Second)); err != nil {
+		t.Fatalf("expected per-call idle timeout to override default, got %v", err)
+	}
+	if data, err := os.ReadFile(schemaCopy); err != nil || !strings.Contains(string(data), `"per-call"`) {
+		t.Errorf("expected per-call schema to override default, got %q (err=%v)", data, err)
+	}
+}
+
+func TestRunStreamedReceivedAt(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.started"}'
+sleep 0.1
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"hi"}}'
+sleep 0.1
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+	start := time.Now()
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	var stamps []time.Time
+	for event := range streamed.Events {
+		stamps = append(stamps, event.ReceivedAt)
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	if len(stamps) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(stamps))
+	}
+	prev := start
+	for i, ts := range stamps {
+		if ts.Before(prev) {
+			t.Errorf("event %d received at %v, before previous %v", i, ts, prev)
+		}
+		prev = ts
+	}
+	if gap := stamps[3].Sub(stamps[1]); gap < 150*time.Millisecond {
+		t.Errorf("expected timestamps to reflect CLI delays, got gap %s", gap)
+	}
+}
+
+func TestRunTurnTempFilesHook(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script)
+
+	var got []string
+	calls := 0
+	hook := WithTurnTempFilesHook(func(paths []string) {
+		calls++
+		got = paths
+	})
+
+	if _, err := thread.Run(testContext(t), Text("hello"), WithOutputSchema(map[string]any{"type": "object"}), hook); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected hook to be called once, got %d", calls)
+	}
+	args := readRecordedArgs(t, argsFile)
+	var schemaPath string
+	for i, arg := range args {
+		if arg == "--output-schema" && i+1 < len(args) {
+			schemaPath = args[i+1]
+		}
+	}
+	if len(got) != 1 || got[0] != schemaPath {
+		t.Errorf("expected hook paths [%q], got %q", schemaPath, got)
+	}
+	if _, err := os.Stat(schemaPath); !os.IsNotExist(err) {
+		t.Errorf("expected schema file to be removed, stat err=%v", err)
+	}
+
+	if _, err := thread.Run(testContext(t), Text("hello"), hook); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 2 || len(got) != 0 {
+		t.Errorf("expected hook with no paths for a turn without temp files, got %d calls and %q", calls, got)
+	}
+}
+
+func TestRunStreamedDecodesUnsplitEvents(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+printf '%s' '{"type":"thread.started","thread_id":"thread-1"}{"type":"turn.started"} '
+echo '{"type":"item.completed","item":{"id":"1","type":"reasoning","text":"a"}}{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"b"}}'
+cat <<'EOF'
+{
+  "type": "turn.completed",
+  "usage": {
+    "input_tokens": 3,
+    "cached_input_tokens": 0,
+    "output_tokens": 4
+  }
+}
+EOF
+`)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	var types []EventType
+	var usage *Usage
+	for event := range streamed.Events {
+		types = append(types, event.Type)
+		if event.Usage != nil {
+			usage = event.Usage
+		}
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	want := []EventType{EventThreadStarted, EventTurnStarted, EventItemCompleted, EventItemCompleted, EventTurnCompleted}
+	if !slices.Equal(types, want) {
+		t.Errorf("expected events %v, got %v", want, types)
+	}
+	if usage == nil || usage.InputTokens != 3 || usage.OutputTokens != 4 {
+		t.Errorf("expected usage from multi-line event, got %+v", usage)
+	}
+	if thread.ID() != "thread-1" {
+		t.Errorf("expected thread ID %q, got %q", "thread-1", thread.ID())
+	}
+}
+
+func TestRunStreamedMalformedOutput(t *testing.T) {
+	for name, body := range map[string]string{
+		"garbage":   `echo 'not json'`,
+		"truncated": `printf '%s' '{"type":"turn.started"'`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			script := createFakeCodexShellScript(t, "cat > /dev/null\n"+body+"\n")
+			thread := newFakeThread(t, script)
+
+			_, err := thread.Run(testContext(t), Text("hello"))
+			if err == nil || !strings.Contains(err.Error(), "parse codex event") {
+				t.Errorf("expected parse error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRunStartupTimeout(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+exec sleep 5
+`)
+	client, err := New(WithCodexPath(script), WithStartupTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.StartThread().Run(testContext(t), Text("hello"))
+	if !errors.Is(err, ErrStartupTimeout) {
+		t.Fatalf("expected ErrStartupTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected startup timeout to kill the process promptly, took %s", elapsed)
+	}
+}
+
+func TestRunStartupTimeoutStopsAfterFirstEvent(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+sleep 0.4
+echo '{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"done"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	client, err := New(WithCodexPath(script), WithStartupTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	turn, err := client.StartThread().Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("expected a slow turn after the first event to succeed, got %v", err)
+	}
+	if turn.FinalResponse != "done" {
+		t.Errorf("expected final response %q, got %q", "done", turn.FinalResponse)
+	}
+}
+
+func TestWithEventFilter(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"reasoning","text":"thinking"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"done"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":3,"cached_input_tokens":0,"output_tokens":2}}`,
+	)
+	client, err := New(WithCodexPath(script), WithEventFilter(func(event ThreadEvent) bool {
+		_, reasoning := event.Item.(*ReasoningItem)
+		return !reasoning
+	}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	streamed, err := client.StartThread().RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	var types []string
+	for event := range streamed.Events {
+		types = append(types, string(event.Type))
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	want := []string{"thread.started", "turn.started", "item.completed", "turn.completed"}
+	if !slices.Equal(types, want) {
+		t.Errorf("expected events %q, got %q", want, types)
+	}
+
+	turn, err := client.StartThread().Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(turn.Items) != 2 {
+		t.Errorf("expected Run to aggregate both items, got %d", len(turn.Items))
+	}
+	if turn.FinalResponse != "done" || turn.Usage == nil || turn.Usage.InputTokens != 3 {
+		t.Errorf("unexpected turn: response %q, usage %+v", turn.FinalResponse, turn.Usage)
+	}
+}
+
+func TestStreamedTurnInterrupt(t *testing.T) {
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"turn.started"}'
+echo '{"type":"item.completed","item":{"id":"1","type":"command_execution","command":"ls","aggregated_output":"","status":"completed"}}'
+echo '{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"first draft"}}'
+sleep 5
+echo '{"type":"item.completed","item":{"id":"3","type":"agent_message","text":"final"}}'
+`)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	if _, ok := streamed.Next(testContext(t), func(e ThreadEvent) bool { return e.Item != nil && e.Item.GetID() == "2" }); !ok {
+		t.Fatal("stream ended before the agent message")
+	}
+
+	start := time.Now()
+	turn, err := streamed.Interrupt()
+	if err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected Interrupt to stop the CLI promptly, took %s", elapsed)
+	}
+	if !turn.Interrupted {
+		t.Error("expected the turn to be marked as interrupted")
+	}
+	if len(turn.Items) != 2 || turn.FinalResponse != "first draft" {
+		t.Errorf("expected the two completed items, got %d items and response %q", len(turn.Items), turn.FinalResponse)
+	}
+	if err := streamed.Wait(); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("expected Wait to report ErrInterrupted, got %v", err)
+	}
+}
+
+func TestStreamedTurnInterruptAfterCompletion(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"done"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	for range streamed.Events {
+	}
+
+	turn, err := streamed.Interrupt()
+	if err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+	if turn.Interrupted || turn.FinalResponse != "done" || turn.Usage == nil {
+		t.Errorf("expected the complete turn, got %+v", turn)
+	}
+}
+
+// spanRecorder records the spans started through WithStartSpan.
+type spanRecorder struct {
+	mu  sync.Mutex
+	log []string
+}
+
+type spanKey struct{}
+
+func (r *spanRecorder) start(ctx context.Context, name string) (context.Context, func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parent, _ := ctx.Value(spanKey{}).(string)
+	r.log = append(r.log, "start "+name+" parent="+parent)
+	return context.WithValue(ctx, spanKey{}, name), func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.log = append(r.log, fmt.Sprintf("end %s err=%v", name, err != nil))
+	}
+}
+
+func (r *spanRecorder) entries() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+func TestWithStartSpan(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"done"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	recorder := &spanRecorder{}
+	client, err := New(WithCodexPath(script), WithStartSpan(recorder.start))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.StartThread().Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []string{
+		"start codex.turn parent=",
+		"start codex.exec parent=codex.turn",
+		"end codex.exec err=false",
+		"end codex.turn err=false",
+	}
+	if got := recorder.entries(); !slices.Equal(got, want) {
+		t.Errorf("expected spans %q, got %q", want, got)
+	}
+}
+
+func TestWithStartSpanError(t *testing.T) {
+	recorder := &spanRecorder{}
+	client, err := New(WithCodexPath(createFakeCodexShellScript(t, "cat > /dev/null\nexit 3\n")), WithStartSpan(recorder.start))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.StartThread()
+
+	if _, err := thread.Run(testContext(t), Text("hello")); err == nil {
+		t.Fatal("expected Run to fail")
+	}
+	want := []string{
+		"start codex.turn parent=",
+		"start codex.exec parent=codex.turn",
+		"end codex.exec err=true",
+		"end codex.turn err=true",
+	}
+	if got := recorder.entries(); !slices.Equal(got, want) {
+		t.Errorf("expected spans %q, got %q", want, got)
+	}
+
+	// A turn rejected before spawning only records the turn span.
+	ctx, cancel := context.WithCancel(testContext(t))
+	cancel()
+	recorder.log = nil
+	if _, err := thread.Run(ctx, Text("hello")); err == nil {
+		t.Fatal("expected Run to fail")
+	}
+	want = []string{"start codex.turn parent=", "end codex.turn err=true"}
+	if got := recorder.entries(); !slices.Equal(got, want) {
+		t.Errorf("expected spans %q, got %q", want, got)
+	}
+}
+
+func TestRunDisableReasoning(t *testing.T) {
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithDisableReasoning())
+
+	if _, err := thread.Run(testContext(t), Text("trivial")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if !containsArgPair(args, "--config", `model_reasoning_effort="minimal"`) {
+		t.Errorf("expected minimal reasoning effort in args, got %q", args)
+	}
+	if !containsArgPair(args, "--config", `model_reasoning_summary="none"`) {
+		t.Errorf("expected reasoning summaries to be disabled, got %q", args)
+	}
+}
+
+func TestRunStreamedBackpressureObserver(t *testing.T) {
+	defer func(threshold time.Duration) { backpressureThreshold = threshold }(backpressureThreshold)
+	backpressureThreshold = 20 * time.Millisecond
+
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"a"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"b"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	var (
+		mu    sync.Mutex
+		waits []time.Duration
+	)
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"), WithBackpressureObserver(func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+	}))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	for range streamed.Events {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(waits) == 0 {
+		t.Fatal("expected the observer to fire for a slow consumer")
+	}
+	for _, d := range waits {
+		if d < backpressureThreshold {
+			t.Errorf("expected waits of at least %s, got %s", backpressureThreshold, d)
+		}
+	}
+}
+
+func TestStreamedTurnFileChanges(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.started","item":{"id":"1","type":"file_change","changes":[{"path":"a.go","kind":"add"}],"status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"1","type":"file_change","changes":[{"path":"a.go","kind":"add","diff":"+package a\n"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"editing"}}`,
+		`{"type":"item.completed","item":{"id":"3","type":"file_change","changes":[{"path":"b.go","kind":"update"}],"status":"failed"}}`,
+		`{"type":"item.completed","item":{"id":"4","type":"file_change","changes":[{"path":"b.go","kind":"update"},{"path":"c.go","kind":"delete"}],"status":"completed"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	streamed, err := thread.RunStreamed(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	var got []FileUpdateChange
+	for change := range streamed.FileChanges() {
+		got = append(got, change)
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	want := []FileUpdateChange{
+		{Path: "a.go", Kind: PatchAdd, Diff: "+package a\n"},
+		{Path: "b.go", Kind: PatchUpdate},
+		{Path: "c.go", Kind: PatchDelete},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected changes %+v, got %+v", want, got)
+	}
+}
+
+func TestStreamedTurnFileChangesStopsOnCancel(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"file_change","changes":[{"path":"a.go","kind":"add"},{"path":"b.go","kind":"add"}],"status":"completed"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+
+	for _, name := range []string{"cancel", "interrupt"} {
+		t.Run(name, func(t *testing.T) {
+			thread := newFakeThread(t, script)
+			ctx, cancel := context.WithCancel(testContext(t))
+			defer cancel()
+
+			streamed, err := thread.RunStreamed(ctx, Text("hello"))
+			if err != nil {
+				t.Fatalf("RunStreamed failed: %v", err)
+			}
+			changes := streamed.FileChanges()
+			if _, ok := <-changes; !ok {
+				t.Fatal("expected a first change")
+			}
+
+			// Stop reading with the second change still pending.
+			if name == "cancel" {
+				cancel()
+			} else {
+				_, _ = streamed.Interrupt()
+			}
+			// Give the forwarding goroutine time to notice before reading
+			// again, so a pending send would be delivered instead.
+			time.Sleep(200 * time.Millisecond)
+			select {
+			case change, ok := <-changes:
+				if ok {
+					t.Errorf("expected FileChanges to close, got %+v", change)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("FileChanges did not close after the consumer stopped")
+			}
+			_ = streamed.Wait()
+		})
+	}
+}
+
+func TestThreadHistory(t *testing.T) {
+	dir := t.TempDir()
+	script := createFakeCodexShellScript(t, `cat > /dev/null
+n=$(cat '`+dir+`/count' 2>/dev/null || echo 0)
+n=$((n + 1))
+echo $n > '`+dir+`/count'
+echo '{"type":"thread.started","thread_id":"thread-1"}'
+echo '{"type":"item.started","item":{"id":"'$n'-cmd","type":"command_execution","command":"ls","aggregated_output":"","status":"in_progress"}}'
+echo '{"type":"item.completed","item":{"id":"'$n'-cmd","type":"command_execution","command":"ls","aggregated_output":"","status":"completed"}}'
+echo '{"type":"item.completed","item":{"id":"'$n'-msg","type":"agent_message","text":"turn '$n'"}}'
+echo '{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}'
+`)
+	thread := newFakeThread(t, script)
+
+	if got := thread.History(); len(got) != 0 {
+		t.Fatalf("expected empty history before any turn, got %d items", len(got))
+	}
+	if _, err := thread.Run(testContext(t), Text("first")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	streamed, err := thread.RunStreamed(testContext(t), Text("second"))
+	if err != nil {
+		t.Fatalf("RunStreamed failed: %v", err)
+	}
+	for range streamed.Events {
+	}
+	if err := streamed.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	var ids []string
+	for _, item := range thread.History() {
+		ids = append(ids, item.GetID())
+	}
+	if want := []string{"1-cmd", "1-msg", "2-cmd", "2-msg"}; !slices.Equal(ids, want) {
+		t.Errorf("expected history %q, got %q", want, ids)
+	}
+}
+
+func TestRunSessionID(t *testing.T) {
+	const id = "0199a213-81c0-7800-8aa1-bbab2a035a53"
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	thread := client.StartThread(WithSessionID(id))
+	if thread.ID() != id {
+		t.Errorf("expected thread ID %q before the first turn, got %q", id, thread.ID())
+	}
+	if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	args := readRecordedArgs(t, argsFile)
+	if want := []string{"resume", id}; !slices.Equal(args[len(args)-2:], want) {
+		t.Errorf("expected args to end with %q, got %q", want, args)
+	}
+
+	if _, err := client.ResumeThread(id, WithSessionID(id)).Run(testContext(t), Text("hello")); err != nil {
+		t.Errorf("expected a matching resume ID to be accepted, got %v", err)
+	}
+
+	other := client.ResumeThread("0199a213-81c0-7800-8aa1-000000000002", WithSessionID(id))
+	if _, err := other.Run(testContext(t), Text("hello")); !errors.Is(err, ErrThreadIDMismatch) {
+		t.Errorf("expected ErrThreadIDMismatch for a conflicting ID, got %v", err)
+	}
+
+	_, err = client.StartThread(WithSessionID("my-session")).Run(testContext(t), Text("hello"))
+	var invalid *ErrInvalidInput
+	if !errors.As(err, &invalid) || invalid.Field != "session ID" {
+		t.Errorf("expected ErrInvalidInput for a non-UUID session ID, got %v", err)
+	}
+}
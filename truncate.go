@@ -0,0 +1,19 @@
+package codex
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// truncateOutput keeps the first limit bytes of s, backing off to a UTF-8
+// character boundary, and appends a marker with the number of bytes dropped.
+func truncateOutput(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("\n[output truncated: %d bytes omitted]", len(s)-cut)
+}
@@ -0,0 +1,52 @@
+package codex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  string
+	}{
+		{"under limit", "hello", 10, "hello"},
+		{"at limit", "hello", 5, "hello"},
+		{"over limit", "hello world", 5, "hello\n[output truncated: 6 bytes omitted]"},
+		{"multibyte boundary", "héllo", 2, "h\n[output truncated: 5 bytes omitted]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateOutput(tt.in, tt.limit); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunCommandOutputLimit(t *testing.T) {
+	output := strings.Repeat("x", 5000)
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"command_execution","command":"make","aggregated_output":"`+output+`","exit_code":0,"status":"completed"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	client, err := New(WithCodexPath(script), WithCommandOutputLimit(100))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	turn, err := client.StartThread().Run(testContext(t), Text("build"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	cmd, ok := turn.Items[0].(*CommandExecutionItem)
+	if !ok {
+		t.Fatalf("expected *CommandExecutionItem, got %T", turn.Items[0])
+	}
+	if want := strings.Repeat("x", 100) + "\n[output truncated: 4900 bytes omitted]"; cmd.AggregatedOutput != want {
+		t.Errorf("expected truncated output, got %d bytes: %q", len(cmd.AggregatedOutput), cmd.AggregatedOutput[:120])
+	}
+}
@@ -0,0 +1,195 @@
+package codex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TurnStats summarizes the items produced during a turn.
+type TurnStats struct {
+	// CommandExecutions counts shell commands run by the agent.
+	CommandExecutions int
+	// FilesChanged counts individual file operations across all file changes.
+	FilesChanged int
+	// WebSearches counts web search requests.
+	WebSearches int
+	// McpToolCalls counts MCP tool invocations.
+	McpToolCalls int
+	// Errors counts non-fatal error items.
+	Errors int
+}
+
+// Stats returns counts of the items in the turn by kind.
+func (t *Turn) Stats() TurnStats {
+	var stats TurnStats
+	for _, item := range t.Items {
+		switch v := item.(type) {
+		case *CommandExecutionItem:
+			stats.CommandExecutions++
+		case *FileChangeItem:
+			stats.FilesChanged += len(v.Changes)
+		case *WebSearchItem:
+			stats.WebSearches++
+		case *McpToolCallItem:
+			stats.McpToolCalls++
+		case *ErrorItem:
+			stats.Errors++
+		}
+	}
+	return stats
+}
+
+// Errors returns the non-fatal error items reported during the turn, in
+// order. A turn that fails outright returns an error from Run instead.
+func (t *Turn) Errors() []*ErrorItem {
+	var errs []*ErrorItem
+	for _, item := range t.Items {
+		if errItem, ok := item.(*ErrorItem); ok {
+			errs = append(errs, errItem)
+		}
+	}
+	return errs
+}
+
+// HasErrors reports whether the turn contains any non-fatal error items.
+func (t *Turn) HasErrors() bool {
+	for _, item := range t.Items {
+		if _, ok := item.(*ErrorItem); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reasoning returns the text of the turn's reasoning items in order, joined
+// by blank lines. Empty reasoning items are skipped. It returns "" when the
+// model produced no reasoning summary.
+func (t *Turn) Reasoning() string {
+	var parts []string
+	for _, item := range t.Items {
+		if reasoning, ok := item.(*ReasoningItem); ok && reasoning.Text != "" {
+			parts = append(parts, reasoning.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// TodoHistory returns every snapshot of the agent's to-do list seen during
+// the turn, in order, from the item.started, item.updated, and item.completed
+// events of todo_list items. The last snapshot is the final plan.
+func (t *Turn) TodoHistory() [][]TodoItem {
+	return t.todoHistory
+}
+
+// FileChanges returns the file operations of every file_change item in the
+// turn as one slice, for example to build a review summary. A path touched by
+// several items appears once, at the position it was first seen, with the
+// kind and diff of its last change.
+func (t *Turn) FileChanges() []FileUpdateChange {
+	var changes []FileUpdateChange
+	index := make(map[string]int)
+	for _, item := range t.Items {
+		fileChange, ok := item.(*FileChangeItem)
+		if !ok {
+			continue
+		}
+		for _, change := range fileChange.Changes {
+			if i, seen := index[change.Path]; seen {
+				changes[i] = change
+				continue
+			}
+			index[change.Path] = len(changes)
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Transcript renders the turn as readable text, one block per item in order,
+// followed by token usage when known. The format is stable, which makes it
+// suitable for logs and test snapshots. Unlike String on events, message,
+// reasoning and command output text is not shortened.
+func (t *Turn) Transcript() string {
+	var b strings.Builder
+	for _, item := range t.Items {
+		writeTranscriptItem(&b, item)
+	}
+	if t.Usage != nil {
+		fmt.Fprintf(&b, "usage: input=%d cached=%d output=%d\n",
+			t.Usage.InputTokens, t.Usage.CachedInputTokens, t.Usage.OutputTokens)
+	}
+	return b.String()
+}
+
+// writeTranscriptItem renders one item for Transcript. Kinds without a
+// detailed rendering fall back to itemSummary.
+func writeTranscriptItem(b *strings.Builder, item ThreadItem) {
+	switch v := item.(type) {
+	case *AgentMessageItem:
+		fmt.Fprintf(b, "message:\n%s", indentLines(v.Text))
+	case *ReasoningItem:
+		fmt.Fprintf(b, "reasoning:\n%s", indentLines(v.Text))
+	case *CommandExecutionItem:
+		fmt.Fprintf(b, "command: %s", v.Command)
+		if v.ExitCode != nil {
+			fmt.Fprintf(b, " (exit %d)", *v.ExitCode)
+		} else {
+			fmt.Fprintf(b, " (%s)", v.Status)
+		}
+		b.WriteString("\n")
+		b.WriteString(indentLines(v.AggregatedOutput))
+	case *FileChangeItem:
+		fmt.Fprintf(b, "file_change: %s\n", v.Status)
+		for _, change := range v.Changes {
+			fmt.Fprintf(b, "  %s %s\n", change.Kind, change.Path)
+		}
+	case *ErrorItem:
+		fmt.Fprintf(b, "error: %s\n", v.Message)
+	default:
+		b.WriteString(itemSummary(item))
+		b.WriteString("\n")
+	}
+}
+
+// indentLines indents each line of s by two spaces and ensures a trailing
+// newline. Empty text renders as nothing.
+func indentLines(s string) string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return ""
+	}
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ") + "\n"
+}
+
+// turnBuilder aggregates the events of a turn into a Turn.
+type turnBuilder struct {
+	turn Turn
+	// failure is the error of a turn.failed event.
+	failure *ThreadError
+}
+
+func (b *turnBuilder) add(event ThreadEvent) {
+	if todo, ok := event.Item.(*TodoListItem); ok {
+		b.turn.todoHistory = append(b.turn.todoHistory, append([]TodoItem(nil), todo.Items...))
+	}
+
+	switch event.Type {
+	case EventItemCompleted:
+		if event.Item != nil {
+			if msg, ok := event.Item.(*AgentMessageItem); ok {
+				b.turn.FinalResponse = msg.Text
+			}
+			b.turn.Items = append(b.turn.Items, event.Item)
+		}
+	case EventTurnStarted:
+		b.turn.StartedAt = event.ReceivedAt
+	case EventTurnCompleted:
+		b.turn.Usage = event.Usage
+		b.turn.CompletedAt = event.ReceivedAt
+	case EventTurnFailed:
+		b.failure = event.Error
+		if b.failure == nil {
+			b.failure = &ThreadError{Message: "turn failed"}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package codex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTurnStats(t *testing.T) {
+	turn := &Turn{Items: []ThreadItem{
+		&AgentMessageItem{ID: "1", Text: "hi"},
+		&ReasoningItem{ID: "2", Text: "thinking"},
+		&CommandExecutionItem{ID: "3", Command: "go test", Status: CommandStatusCompleted},
+		&CommandExecutionItem{ID: "4", Command: "go vet", Status: CommandStatusFailed},
+		&FileChangeItem{ID: "5", Changes: []FileUpdateChange{
+			{Path: "a.go", Kind: PatchUpdate},
+			{Path: "b.go", Kind: PatchAdd},
+		}},
+		&FileChangeItem{ID: "6", Changes: []FileUpdateChange{{Path: "c.go", Kind: PatchDelete}}},
+		&WebSearchItem{ID: "7", Query: "golang"},
+		&McpToolCallItem{ID: "8", Server: "s", Tool: "t"},
+		&ErrorItem{ID: "9", Message: "oops"},
+		&UnknownItem{ItemType: "future"},
+	}}
+
+	want := TurnStats{
+		CommandExecutions: 2,
+		FilesChanged:      3,
+		WebSearches:       1,
+		McpToolCalls:      1,
+		Errors:            1,
+	}
+	if got := turn.Stats(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if got := (&Turn{}).Stats(); got != (TurnStats{}) {
+		t.Errorf("expected zero stats for an empty turn, got %+v", got)
+	}
+}
+
+func TestTurnTodoHistory(t *testing.T) {
+	script := createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.started","item":{"id":"t","type":"todo_list","items":[{"text":"plan","completed":false}]}}`,
+		`{"type":"item.updated","item":{"id":"t","type":"todo_list","items":[{"text":"plan","completed":true},{"text":"build","completed":false}]}}`,
+		`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"working"}}`,
+		`{"type":"item.completed","item":{"id":"t","type":"todo_list","items":[{"text":"plan","completed":true},{"text":"build","completed":true}]}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	)
+	thread := newFakeThread(t, script)
+
+	turn, err := thread.Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := [][]TodoItem{
+		{{Text: "plan"}},
+		{{Text: "plan", Completed: true}, {Text: "build"}},
+		{{Text: "plan", Completed: true}, {Text: "build", Completed: true}},
+	}
+	if got := turn.TodoHistory(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected history %+v, got %+v", want, got)
+	}
+}
+
+func TestTurnFileChanges(t *testing.T) {
+	turn := &Turn{Items: []ThreadItem{
+		&FileChangeItem{ID: "1", Changes: []FileUpdateChange{
+			{Path: "a.go", Kind: PatchAdd},
+			{Path: "b.go", Kind: PatchUpdate},
+		}},
+		&AgentMessageItem{ID: "2", Text: "halfway"},
+		&FileChangeItem{ID: "3", Changes: []FileUpdateChange{
+			{Path: "a.go", Kind: PatchUpdate, Diff: "+x"},
+			{Path: "c.go", Kind: PatchAdd},
+		}},
+		&FileChangeItem{ID: "4", Changes: []FileUpdateChange{{Path: "b.go", Kind: PatchDelete}}},
+	}}
+
+	want := []FileUpdateChange{
+		{Path: "a.go", Kind: PatchUpdate, Diff: "+x"},
+		{Path: "b.go", Kind: PatchDelete},
+		{Path: "c.go", Kind: PatchAdd},
+	}
+	if got := turn.FileChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if got := (&Turn{}).FileChanges(); got != nil {
+		t.Errorf("expected no changes for an empty turn, got %+v", got)
+	}
+}
+
+func TestTurnErrors(t *testing.T) {
+	first := &ErrorItem{ID: "2", Message: "command output truncated"}
+	second := &ErrorItem{ID: "4", Message: "mcp server unavailable"}
+	turn := &Turn{Items: []ThreadItem{
+		&AgentMessageItem{ID: "1", Text: "working"},
+		first,
+		&CommandExecutionItem{ID: "3", Command: "ls", Status: CommandStatusCompleted},
+		second,
+	}}
+
+	if !turn.HasErrors() {
+		t.Error("expected HasErrors to report the error items")
+	}
+	if got := turn.Errors(); !reflect.DeepEqual(got, []*ErrorItem{first, second}) {
+		t.Errorf("expected both error items in order, got %+v", got)
+	}
+
+	clean := &Turn{Items: []ThreadItem{&AgentMessageItem{ID: "1", Text: "done"}}}
+	if clean.HasErrors() || clean.Errors() != nil {
+		t.Errorf("expected no errors, got %+v", clean.Errors())
+	}
+}
+
+func TestTurnTranscript(t *testing.T) {
+	exitZero, exitOne := 0, 1
+	turn := &Turn{
+		Items: []ThreadItem{
+			&ReasoningItem{ID: "1", Text: "Need to run the tests first."},
+			&CommandExecutionItem{ID: "2", Command: "go test ./...", AggregatedOutput: "--- FAIL: TestAdd\nFAIL\n", ExitCode: &exitOne, Status: CommandStatusFailed},
+			&FileChangeItem{ID: "3", Status: PatchCompleted, Changes: []FileUpdateChange{
+				{Path: "add.go", Kind: PatchUpdate},
+				{Path: "add_test.go", Kind: PatchAdd},
+			}},
+			&CommandExecutionItem{ID: "4", Command: "go test ./...", AggregatedOutput: "ok\n", ExitCode: &exitZero, Status: CommandStatusCompleted},
+			&CommandExecutionItem{ID: "5", Command: "sleep 100", Status: CommandStatusInProgress},
+			&WebSearchItem{ID: "6", Query: "go testing"},
+			&ErrorItem{ID: "7", Message: "rate limited"},
+			&AgentMessageItem{ID: "8", Text: "Fixed Add.\nAll tests pass."},
+		},
+		Usage: &Usage{InputTokens: 120, CachedInputTokens: 20, OutputTokens: 45},
+	}
+
+	const want = `reasoning:
+  Need to run the tests first.
+command: go test ./... (exit 1)
+  --- FAIL: TestAdd
+  FAIL
+file_change: completed
+  update add.go
+  add add_test.go
+command: go test ./... (exit 0)
+  ok
+command: sleep 100 (in_progress)
+web_search query="go testing"
+error: rate limited
+message:
+  Fixed Add.
+  All tests pass.
+usage: input=120 cached=20 output=45
+`
+	if got := turn.Transcript(); got != want {
+		t.Errorf("transcript mismatch\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := (&Turn{}).Transcript(); got != "" {
+		t.Errorf("expected empty transcript, got %q", got)
+	}
+}
+
+func TestTurnReasoning(t *testing.T) {
+	turn := &Turn{Items: []ThreadItem{
+		&ReasoningItem{ID: "1", Text: "**Planning** Look at the failing test."},
+		&CommandExecutionItem{ID: "2", Command: "go test"},
+		&ReasoningItem{ID: "3", Text: ""},
+		&ReasoningItem{ID: "4", Text: "**Fixing** The off-by-one is in Add."},
+		&AgentMessageItem{ID: "5", Text: "Fixed."},
+	}}
+	want := "**Planning** Look at the failing test.\n\n**Fixing** The off-by-one is in Add."
+	if got := turn.Reasoning(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := (&Turn{}).Reasoning(); got != "" {
+		t.Errorf("expected no reasoning for an empty turn, got %q", got)
+	}
+}
+
+func TestRunCollectsReasoning(t *testing.T) {
+	thread := newFakeThread(t, createFakeCodexEventsScript(t,
+		`{"type":"thread.started","thread_id":"thread-1"}`,
+		`{"type":"item.completed","item":{"id":"1","type":"reasoning","text":"first"}}`,
+		`{"type":"item.completed","item":{"id":"2","type":"agent_message","text":"done"}}`,
+		`{"type":"item.completed","item":{"id":"3","type":"reasoning","text":"second"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	))
+	turn, err := thread.Run(testContext(t), Text("hello"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := turn.Reasoning(); got != "first\n\nsecond" {
+		t.Errorf("expected reasoning from Run, got %q", got)
+	}
+}
@@ -2,9 +2,109 @@ package codex
 
 import (
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+// validateThreadOptions checks thread options that cannot be validated when
+// the option is applied.
+func validateThreadOptions(opts ThreadOptions) error {
+	switch opts.ModelVerbosity {
+	case "", VerbosityLow, VerbosityMedium, VerbosityHigh:
+	default:
+		return &ErrInvalidInput{
+			Field:  "model verbosity",
+			Value:  string(opts.ModelVerbosity),
+			Reason: "must be one of low, medium, high",
+		}
+	}
+
+	if opts.ModelContextWindow != nil && *opts.ModelContextWindow <= 0 {
+		return &ErrInvalidInput{
+			Field:  "model context window",
+			Value:  strconv.Itoa(*opts.ModelContextWindow),
+			Reason: "must be positive",
+		}
+	}
+
+	switch opts.ShellEnvironmentPolicy {
+	case "", ShellEnvironmentAll, ShellEnvironmentCore, ShellEnvironmentNone:
+	default:
+		return &ErrInvalidInput{
+			Field:  "shell environment policy",
+			Value:  string(opts.ShellEnvironmentPolicy),
+			Reason: "must be one of all, core, none",
+		}
+	}
+
+	if opts.SessionID != "" && !uuidPattern.MatchString(opts.SessionID) {
+		return &ErrInvalidInput{
+			Field:  "session ID",
+			Value:  opts.SessionID,
+			Reason: "must be a UUID",
+		}
+	}
+
+	if opts.InstructionsFile != "" {
+		if opts.Instructions != "" {
+			return &ErrInvalidInput{
+				Field:  "instructions file",
+				Value:  opts.InstructionsFile,
+				Reason: "cannot be combined with inline instructions",
+			}
+		}
+		path, err := filepath.Abs(opts.InstructionsFile)
+		if err != nil {
+			return &ErrInvalidInput{
+				Field:  "instructions file",
+				Value:  opts.InstructionsFile,
+				Reason: "cannot resolve path: " + err.Error(),
+			}
+		}
+		if err := validateReadableFile("instructions file", path); err != nil {
+			return err
+		}
+	}
+
+	for _, root := range opts.WritableRoots {
+		if err := validatePath("writable root", root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uuidPattern matches the UUIDs the CLI uses as session IDs.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateTurnOptions checks turn options that cannot be validated when the
+// option is applied.
+func validateTurnOptions(opts TurnOptions) error {
+	if opts.ImageMaxDimension < 0 {
+		return &ErrInvalidInput{
+			Field:  "image max dimension",
+			Value:  strconv.Itoa(opts.ImageMaxDimension),
+			Reason: "must not be negative",
+		}
+	}
+	if opts.ImageQuality < 0 || opts.ImageQuality > 100 {
+		return &ErrInvalidInput{
+			Field:  "image quality",
+			Value:  strconv.Itoa(opts.ImageQuality),
+			Reason: "must be between 1 and 100",
+		}
+	}
+
+	if opts.PromptCacheKey != nil {
+		if err := validateNonEmpty("prompt cache key", *opts.PromptCacheKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateNonEmpty checks if a string is non-empty after trimming whitespace.
 // Returns an ErrInvalidInput if the string is empty.
 func validateNonEmpty(field, value string) error {
@@ -47,6 +147,25 @@ func validatePath(field, path string) error {
 	return nil
 }
 
+// validateReadableFile checks if a path exists and can be opened for reading.
+// Returns an ErrInvalidInput if the path is missing or unreadable.
+func validateReadableFile(field, path string) error {
+	if err := validatePath(field, path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return &ErrInvalidInput{
+			Field:  field,
+			Value:  path,
+			Reason: "path is not readable: " + err.Error(),
+		}
+	}
+	_ = f.Close()
+	return nil
+}
+
 // validateExecutablePath checks if a path exists and is a regular file.
 // Returns an ErrInvalidInput if the path is invalid or a directory.
 func validateExecutablePath(field, path string) error {
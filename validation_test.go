@@ -224,3 +224,87 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestValidateThreadOptions_ShellEnvironmentPolicy(t *testing.T) {
+	for _, policy := range []ShellEnvironmentPolicy{"", ShellEnvironmentAll, ShellEnvironmentCore, ShellEnvironmentNone} {
+		if err := validateThreadOptions(ThreadOptions{ShellEnvironmentPolicy: policy}); err != nil {
+			t.Errorf("expected policy %q to be valid, got: %v", policy, err)
+		}
+	}
+
+	err := validateThreadOptions(ThreadOptions{ShellEnvironmentPolicy: "inherit"})
+	var invalidInput *ErrInvalidInput
+	if !errors.As(err, &invalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+	if invalidInput.Value != "inherit" {
+		t.Errorf("expected value %q, got %q", "inherit", invalidInput.Value)
+	}
+}
+
+func TestValidateThreadOptions_WritableRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := validateThreadOptions(ThreadOptions{WritableRoots: []string{tmpDir}}); err != nil {
+		t.Errorf("expected existing writable root to be valid, got: %v", err)
+	}
+
+	err := validateThreadOptions(ThreadOptions{WritableRoots: []string{tmpDir, filepath.Join(tmpDir, "missing")}})
+	var invalidInput *ErrInvalidInput
+	if !errors.As(err, &invalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+	if invalidInput.Field != "writable root" {
+		t.Errorf("expected field %q, got %q", "writable root", invalidInput.Field)
+	}
+}
+
+func TestValidateThreadOptions_ModelContextWindow(t *testing.T) {
+	if err := validateThreadOptions(applyThreadOptions([]ThreadOption{WithModelContextWindow(200000)})); err != nil {
+		t.Errorf("expected positive context window to be valid, got: %v", err)
+	}
+
+	for _, tokens := range []int{0, -1} {
+		err := validateThreadOptions(applyThreadOptions([]ThreadOption{WithModelContextWindow(tokens)}))
+		var invalidInput *ErrInvalidInput
+		if !errors.As(err, &invalidInput) {
+			t.Errorf("expected ErrInvalidInput for context window %d, got %v", tokens, err)
+		}
+	}
+}
+
+func TestValidateThreadOptions_ModelVerbosity(t *testing.T) {
+	for _, level := range []ModelVerbosity{"", VerbosityLow, VerbosityMedium, VerbosityHigh} {
+		if err := validateThreadOptions(ThreadOptions{ModelVerbosity: level}); err != nil {
+			t.Errorf("expected verbosity %q to be valid, got: %v", level, err)
+		}
+	}
+
+	err := validateThreadOptions(ThreadOptions{ModelVerbosity: "loud"})
+	var invalidInput *ErrInvalidInput
+	if !errors.As(err, &invalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+	if invalidInput.Field != "model verbosity" {
+		t.Errorf("expected field %q, got %q", "model verbosity", invalidInput.Field)
+	}
+}
+
+func TestValidateTurnOptions_PromptCacheKey(t *testing.T) {
+	if err := validateTurnOptions(TurnOptions{}); err != nil {
+		t.Errorf("expected unset prompt cache key to be valid, got: %v", err)
+	}
+	if err := validateTurnOptions(applyTurnOptions([]TurnOption{WithPromptCacheKey("key")})); err != nil {
+		t.Errorf("expected prompt cache key to be valid, got: %v", err)
+	}
+
+	for _, key := range []string{"", "  "} {
+		err := validateTurnOptions(applyTurnOptions([]TurnOption{WithPromptCacheKey(key)}))
+		var invalidInput *ErrInvalidInput
+		if !errors.As(err, &invalidInput) {
+			t.Fatalf("expected ErrInvalidInput for key %q, got %v", key, err)
+		}
+		if invalidInput.Field != "prompt cache key" {
+			t.Errorf("expected field %q, got %q", "prompt cache key", invalidInput.Field)
+		}
+	}
+}
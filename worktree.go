@@ -0,0 +1,121 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// workingDirectory returns the directory the CLI runs in. With
+// WithGitWorktree it is the matching directory inside the thread's worktree,
+// which is created on first use and shared by every turn of the thread.
+func (t *Thread) workingDirectory(ctx context.Context) (string, error) {
+	branch := t.threadOptions.GitWorktreeBranch
+	if branch == "" {
+		return t.threadOptions.WorkingDirectory, nil
+	}
+
+	// git runs without t.mu held, so a turn creating the worktree does not
+	// block the rest of the thread. Concurrent turns wait for it instead of
+	// adding the branch a second time.
+	t.mu.Lock()
+	for t.worktreeDir == "" && t.worktreePending != nil {
+		pending := t.worktreePending
+		t.mu.Unlock()
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		t.mu.Lock()
+	}
+	if t.worktreeDir != "" {
+		defer t.mu.Unlock()
+		return t.worktreeDir, nil
+	}
+	pending := make(chan struct{})
+	t.worktreePending = pending
+	t.mu.Unlock()
+
+	dir, err := t.addWorktree(ctx, branch)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.worktreePending = nil
+	close(pending)
+	if err != nil {
+		return "", err
+	}
+	t.worktreeDir = dir
+	return dir, nil
+}
+
+// addWorktree creates a worktree on a new branch for the repository holding
+// the thread's working directory and returns the matching directory inside
+// it. The worktree is removed when the client is closed.
+func (t *Thread) addWorktree(ctx context.Context, branch string) (string, error) {
+	base := t.threadOptions.WorkingDirectory
+	if base == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		base = wd
+	}
+	// git reports the top level with symlinks resolved, so resolve base too
+	// before computing the relative path.
+	resolved, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", &ErrInvalidInput{Field: "working directory", Value: base, Reason: "path does not exist"}
+	}
+	base = resolved
+
+	top, err := runGit(ctx, base, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", &ErrInvalidInput{
+			Field:  "git worktree",
+			Value:  base,
+			Reason: "working directory is not inside a git repository",
+		}
+	}
+	rel, err := filepath.Rel(top, base)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "codex-worktree-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, top, "worktree", "add", "-b", branch, dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("create git worktree: %w", err)
+	}
+
+	t.tempDirs.addFunc(func() error {
+		_, err := runGit(context.Background(), top, "worktree", "remove", "--force", dir)
+		if rmErr := os.RemoveAll(dir); err == nil {
+			err = rmErr
+		}
+		return err
+	})
+	return filepath.Join(dir, rel), nil
+}
+
+// runGit runs git in dir and returns its trimmed standard output.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,135 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// createGitRepo initializes a repository with one commit and a subdirectory.
+func createGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, "sub"), 0o755); err != nil {
+		t.Fatalf("failed to create subdirectory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "sub", "file.txt"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := runGit(context.Background(), repo, args...); err != nil {
+			t.Fatalf("failed to set up repository: %v", err)
+		}
+	}
+	return repo
+}
+
+func TestRunGitWorktree(t *testing.T) {
+	repo := createGitRepo(t)
+	script, argsFile := createFakeCodexArgsRecorder(t)
+	client, err := New(WithCodexPath(script))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	thread := client.StartThread(WithWorkingDirectory(filepath.Join(repo, "sub")), WithGitWorktree("codex/experiment"))
+
+	var dirs []string
+	for i := 0; i < 2; i++ {
+		if _, err := thread.Run(testContext(t), Text("hello")); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		args := readRecordedArgs(t, argsFile)
+		for j, arg := range args {
+			if arg == "--cd" && j+1 < len(args) {
+				dirs = append(dirs, args[j+1])
+			}
+		}
+	}
+	if len(dirs) != 2 || dirs[0] != dirs[1] {
+		t.Fatalf("expected both turns to use the same worktree directory, got %q", dirs)
+	}
+	dir := dirs[0]
+	if filepath.Base(dir) != "sub" {
+		t.Errorf("expected working directory to map into the worktree, got %q", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "file.txt")); err != nil {
+		t.Errorf("expected checked-out file in worktree: %v", err)
+	}
+	if branch, err := runGit(context.Background(), dir, "branch", "--show-current"); err != nil || branch != "codex/experiment" {
+		t.Errorf("expected worktree on branch %q, got %q (err=%v)", "codex/experiment", branch, err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(dir)); !os.IsNotExist(err) {
+		t.Errorf("expected worktree to be removed, stat err=%v", err)
+	}
+	list, err := runGit(context.Background(), repo, "worktree", "list", "--porcelain")
+	if err != nil {
+		t.Fatalf("git worktree list failed: %v", err)
+	}
+	if strings.Count(list, "worktree ") != 1 {
+		t.Errorf("expected only the main worktree after Close, got:\n%s", list)
+	}
+	if _, err := runGit(context.Background(), repo, "rev-parse", "--verify", "codex/experiment"); err != nil {
+		t.Errorf("expected branch to be kept after Close: %v", err)
+	}
+}
+
+func TestGitWorktreeConcurrentTurns(t *testing.T) {
+	repo := createGitRepo(t)
+	client, err := New(WithCodexPath("/custom/codex"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+	thread := client.StartThread(WithWorkingDirectory(repo), WithGitWorktree("codex/concurrent"))
+
+	dirs := make([]string, 4)
+	errs := make([]error, len(dirs))
+	var wg sync.WaitGroup
+	for i := range dirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dirs[i], errs[i] = thread.workingDirectory(testContext(t))
+		}()
+	}
+	wg.Wait()
+
+	for i := range dirs {
+		if errs[i] != nil {
+			t.Fatalf("workingDirectory failed: %v", errs[i])
+		}
+		if dirs[i] != dirs[0] {
+			t.Errorf("expected every turn to share one worktree, got %q", dirs)
+		}
+	}
+}
+
+func TestRunGitWorktreeOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	script, _ := createFakeCodexArgsRecorder(t)
+	thread := newFakeThread(t, script, WithWorkingDirectory(t.TempDir()), WithGitWorktree("codex/experiment"))
+
+	var invalidInput *ErrInvalidInput
+	if _, err := thread.Run(testContext(t), Text("hello")); !errors.As(err, &invalidInput) {
+		t.Errorf("expected ErrInvalidInput outside a git repository, got %v", err)
+	}
+}